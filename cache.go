@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// summaryCache keeps the last summaries of the configured Prometheus
+// instances, for the long running commands. The summaries are either
+// refreshed on an interval, or collected on demand and cached for a TTL.
+type summaryCache struct {
+	promCfgs map[string]PrometheusConfig
+	// interval is the refresh interval, if it is 0 the summaries are
+	// collected on demand, once they are older than ttl.
+	interval time.Duration
+	ttl      time.Duration
+	timeout  time.Duration
+	// withRules fetches the rule groups along with the summaries.
+	withRules bool
+
+	mu          sync.Mutex
+	results     []*PromSummary
+	collectedAt time.Time
+	duration    time.Duration
+}
+
+func newSummaryCache(promCfgs map[string]PrometheusConfig, interval, ttl, timeout time.Duration,
+	withRules bool) *summaryCache {
+	return &summaryCache{promCfgs: promCfgs, interval: interval, ttl: ttl, timeout: timeout, withRules: withRules}
+}
+
+// collect collects the summaries of all the Prometheus instances,
+// sorted by name.
+func (c *summaryCache) collect() ([]*PromSummary, time.Time, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	start := time.Now()
+	results := collectAll(ctx, c.promCfgs, c.withRules, nil)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, start, time.Since(start)
+}
+
+// refresh collects the summaries and replaces the cached ones, the
+// readers get the previous summaries meanwhile.
+func (c *summaryCache) refresh() []*PromSummary {
+	results, collectedAt, duration := c.collect()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results, c.collectedAt, c.duration = results, collectedAt, duration
+	return results
+}
+
+// summaries returns the cached summaries, the time and the duration of
+// their collection. When they are collected on demand, they are collected
+// again once they are older than the TTL, the concurrent readers wait for
+// the same collection.
+func (c *summaryCache) summaries() ([]*PromSummary, time.Time, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval == 0 && time.Since(c.collectedAt) >= c.ttl {
+		c.results, c.collectedAt, c.duration = c.collect()
+	}
+	return c.results, c.collectedAt, c.duration
+}
+
+// run refreshes the summaries on every interval, until the context is done.
+func (c *summaryCache) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	promclient "github.com/prometheus/client_golang/api"
+	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+func initClient(address, username, password string) (prometheus.API, error) {
+	promCfg := promclient.Config{Address: address}
+	if username != "" && password != "" {
+		promCfg.RoundTripper = &BasicAuthTransport{
+			Username: username,
+			Password: password,
+		}
+	}
+	client, err := promclient.NewClient(promCfg)
+	if err != nil {
+		return nil, err
+	}
+	api := prometheus.NewAPI(client)
+	return api, nil
+}
+
+// collectAll collects the summaries of all the Prometheus instances
+// concurrently. onRecord, if not nil, is called with every summary as soon
+// as it is collected, always from the calling goroutine.
+func collectAll(ctx context.Context, promCfgs map[string]PrometheusConfig, withRules bool,
+	onRecord func(*PromSummary)) []*PromSummary {
+	var (
+		wg      sync.WaitGroup
+		results []*PromSummary
+	)
+	recordCh := make(chan *PromSummary)
+	for k, v := range promCfgs {
+		wg.Add(1)
+		go func(promName string, promCfg PrometheusConfig) {
+			defer wg.Done()
+			recordCh <- collect(ctx, promName, promCfg, withRules)
+		}(k, v)
+	}
+	go func() {
+		wg.Wait()
+		close(recordCh)
+	}()
+	for record := range recordCh {
+		results = append(results, record)
+		if onRecord != nil {
+			onRecord(record)
+		}
+	}
+	return results
+}
+
+// collect gathers the summary of a single Prometheus instance. It never
+// returns nil, errors are recorded in the returned summary's status. The
+// rule groups are only fetched withRules, for the commands showing them.
+func collect(ctx context.Context, promName string, promCfg PrometheusConfig, withRules bool) *PromSummary {
+	record := &PromSummary{
+		Name:        promName,
+		Address:     promCfg.Address,
+		Status:      PromStatusOK,
+		CollectedAt: time.Now().UTC(),
+		Labels:      promCfg.Labels,
+	}
+	promAPI, err := initClient(promCfg.Address, promCfg.BasicAuth.Username,
+		promCfg.BasicAuth.Password)
+	if err != nil {
+		record.setStatus(errors.Wrapf(err, "Error initializing Prometheus API client"))
+		return record
+	}
+	// Get version
+	buildInfo, err := promAPI.Buildinfo(ctx)
+	if err != nil {
+		record.setStatus(errors.Wrapf(err, "Error getting build info"))
+		return record
+	}
+	record.Version = buildInfo.Version
+	// Ger number of targets
+	targets, err := promAPI.Targets(ctx)
+	if err != nil {
+		record.setStatus(errors.Wrapf(err, "Error getting targets"))
+		return record
+	}
+	record.NumOfActiveTargets = strconv.Itoa(len(targets.Active))
+	record.NumOfDroppedTargets = strconv.Itoa(len(targets.Dropped))
+	record.Details = &PromDetails{
+		TargetsByJob: targetsByJob(targets.Active),
+	}
+	// Get top cardinality metrics, the TSDB status endpoint is only
+	// available since Prometheus v2.15, so this is not treated as an error.
+	tsdb, err := promAPI.TSDB(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error getting TSDB stats of %s", promName))
+	} else {
+		for _, stat := range tsdb.SeriesCountByMetricName {
+			record.Details.TopMetrics = append(record.Details.TopMetrics, MetricCardinality{
+				Name:   stat.Name,
+				Series: stat.Value,
+			})
+		}
+	}
+	// Get rule groups, a failure is not treated as an error either.
+	if withRules {
+		rules, err := promAPI.Rules(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error getting rules of %s", promName))
+		} else {
+			record.Details.RuleGroups = ruleGroupStats(rules.Groups)
+		}
+	}
+	// Get storage retention
+	runtimeInfo, err := promAPI.Runtimeinfo(ctx)
+	if err != nil {
+		record.setStatus(errors.Wrapf(err, "Error getting runtime info"))
+		return record
+	}
+	record.Details.Runtime = &RuntimeInfo{
+		StartTime:           runtimeInfo.StartTime,
+		ReloadConfigSuccess: runtimeInfo.ReloadConfigSuccess,
+		LastConfigTime:      runtimeInfo.LastConfigTime,
+		CorruptionCount:     runtimeInfo.CorruptionCount,
+		GoroutineCount:      runtimeInfo.GoroutineCount,
+		GOMAXPROCS:          runtimeInfo.GOMAXPROCS,
+		GOGC:                runtimeInfo.GOGC,
+	}
+	record.StorageRetention = runtimeInfo.StorageRetention
+	// Get number of time series
+	record.NumOfTimeSeries = strconv.Itoa(runtimeInfo.TimeSeriesCount)
+	// Get number of chunks
+	record.NumOfChunks = strconv.Itoa(runtimeInfo.ChunkCount)
+	// Get number of ingested samples per second
+	val, _, err := promAPI.Query(ctx, "rate(prometheus_tsdb_head_samples_appended_total[5m])", time.Now())
+	if err != nil {
+		record.setStatus(errors.Wrapf(err, "Error querying metrics"))
+		return record
+	}
+	switch v := val.(type) {
+	case model.Vector:
+		total := 0.0
+		for _, s := range v {
+			total += float64(s.Value)
+		}
+		record.NumOfIngestedSamplesPerSec = strconv.FormatFloat(total/float64(len(v)), 'E', -1, 64)
+	default:
+		record.setStatus(errors.Errorf("unsupported type: '%q'", v))
+		return record
+	}
+	return record
+}
+
+// targetsByJob groups the active targets by job and health.
+func targetsByJob(active []prometheus.ActiveTarget) []JobTargets {
+	var (
+		jobs  []JobTargets
+		index = make(map[string]int)
+	)
+	for _, t := range active {
+		job := string(t.Labels[model.JobLabel])
+		i, ok := index[job]
+		if !ok {
+			i = len(jobs)
+			index[job] = i
+			jobs = append(jobs, JobTargets{Job: job})
+		}
+		switch t.Health {
+		case prometheus.HealthGood:
+			jobs[i].Up++
+		case prometheus.HealthBad:
+			jobs[i].Down++
+		default:
+			jobs[i].Unknown++
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Job < jobs[j].Job })
+	return jobs
+}
+
+// ruleGroupStats counts the rules of every group, the unhealthy ones and
+// the firing alerts.
+func ruleGroupStats(groups []prometheus.RuleGroup) []RuleGroupStats {
+	stats := make([]RuleGroupStats, 0, len(groups))
+	for _, g := range groups {
+		s := RuleGroupStats{Name: g.Name, File: g.File, Rules: len(g.Rules)}
+		for _, rule := range g.Rules {
+			switch r := rule.(type) {
+			case prometheus.AlertingRule:
+				if r.Health == prometheus.RuleHealthBad {
+					s.Unhealthy++
+				}
+				for _, a := range r.Alerts {
+					if a.State == prometheus.AlertStateFiring {
+						s.Firing++
+					}
+				}
+			case prometheus.RecordingRule:
+				if r.Health == prometheus.RuleHealthBad {
+					s.Unhealthy++
+				}
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakePrometheus answers the API endpoints used by collect, and counts
+// the requests by path.
+type fakePrometheus struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+var fakePrometheusResponses = map[string]string{
+	"/api/v1/status/buildinfo": `{"version":"2.25.0","revision":"x","branch":"HEAD","buildUser":"u","buildDate":"d","goVersion":"go1.15"}`,
+	"/api/v1/status/runtimeinfo": `{"startTime":"2021-03-01T00:00:00Z","CWD":"/","reloadConfigSuccess":true,` +
+		`"lastConfigTime":"2021-03-01T00:00:00Z","chunkCount":2000,"timeSeriesCount":1000,"corruptionCount":0,` +
+		`"goroutineCount":10,"GOMAXPROCS":4,"GOGC":"","GODEBUG":"","storageRetention":"15d"}`,
+	"/api/v1/targets": `{"activeTargets":[{"discoveredLabels":{},"labels":{"job":"node","instance":"a"},` +
+		`"scrapePool":"node","scrapeUrl":"http://a/metrics","lastError":"","lastScrape":"2021-03-01T00:00:00Z",` +
+		`"lastScrapeDuration":0.01,"health":"up"}],"droppedTargets":[]}`,
+	"/api/v1/status/tsdb": `{"seriesCountByMetricName":[{"name":"up","value":100}],"labelValueCountByLabelName":[],` +
+		`"memoryInBytesByLabelName":[],"seriesCountByLabelValuePair":[]}`,
+	"/api/v1/rules": `{"groups":[{"name":"node","file":"/etc/rules.yml","interval":30,"rules":[` +
+		`{"name":"job:up:sum","query":"sum(up)","labels":{},"health":"ok","type":"recording"}]}]}`,
+	"/api/v1/query": `{"resultType":"vector","result":[{"metric":{},"value":[1614556800,"1500"]}]}`,
+}
+
+func (p *fakePrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.calls[r.URL.Path]++
+	p.mu.Unlock()
+	data, ok := fakePrometheusResponses[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"success","data":%s}`, data)
+}
+
+func TestCollect(t *testing.T) {
+	for _, withRules := range []bool{false, true} {
+		prom := &fakePrometheus{calls: make(map[string]int)}
+		srv := httptest.NewServer(prom)
+		ps := collect(context.Background(), "p1", PrometheusConfig{Address: srv.URL}, withRules)
+		srv.Close()
+
+		if ps.Status != PromStatusOK {
+			t.Fatalf("unexpected status %s: %s", ps.Status, ps.Error)
+		}
+		if ps.Version != "2.25.0" || ps.NumOfTimeSeries != "1000" || ps.NumOfActiveTargets != "1" {
+			t.Errorf("unexpected summary %+v", ps)
+		}
+		if withRules != (prom.calls["/api/v1/rules"] == 1) {
+			t.Errorf("withRules %t: unexpected rules requests %d", withRules, prom.calls["/api/v1/rules"])
+		}
+		if withRules != (len(ps.Details.RuleGroups) == 1) {
+			t.Errorf("withRules %t: unexpected rule groups %+v", withRules, ps.Details.RuleGroups)
+		}
+	}
+}
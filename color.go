@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+)
+
+// Color modes of the --color flag.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// useColor tells whether the table written to file, stdout if empty,
+// is colourised. The files are never colourised, even in always mode, so
+// that no escape sequence ends up in the reports. In auto mode, colours are
+// only used for a terminal and can be disabled with the NO_COLOR
+// environment variable.
+func useColor(mode, file string) bool {
+	if file != "" {
+		return false
+	}
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// validateThresholds checks the thresholds are set on numeric columns.
+func validateThresholds(thresholds map[string]float64) error {
+	for name := range thresholds {
+		c, err := lookupColumn(name)
+		if err != nil {
+			return errors.Wrap(err, "invalid threshold")
+		}
+		if !c.Numeric {
+			return errors.Errorf("invalid threshold, column %q is not numeric", name)
+		}
+	}
+	return nil
+}
+
+// crossesThreshold tells whether the column value of the summary is above
+// its configured threshold.
+func crossesThreshold(thresholds map[string]float64, c Column, ps *PromSummary) bool {
+	limit, ok := thresholds[c.Name]
+	if !ok {
+		return false
+	}
+	v, err := strconv.ParseFloat(c.Value(ps), 64)
+	return err == nil && v > limit
+}
+
+// isDegraded tells whether an OK summary has at least one value above
+// its threshold.
+func isDegraded(thresholds map[string]float64, ps *PromSummary) bool {
+	if ps.Status != PromStatusOK {
+		return false
+	}
+	for name := range thresholds {
+		if c, err := lookupColumn(name); err == nil && crossesThreshold(thresholds, c, ps) {
+			return true
+		}
+	}
+	return false
+}
+
+// rowColors returns the colours of the row cells: NotOK rows are red,
+// degraded rows are yellow, and the cells above their threshold are bold red.
+func rowColors(columns []Column, thresholds map[string]float64, ps *PromSummary) []tablewriter.Colors {
+	colors := make([]tablewriter.Colors, len(columns))
+	for i, c := range columns {
+		switch {
+		case ps.Status != PromStatusOK:
+			colors[i] = tablewriter.Colors{tablewriter.FgRedColor}
+		case crossesThreshold(thresholds, c, ps):
+			colors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+		case isDegraded(thresholds, ps):
+			colors[i] = tablewriter.Colors{tablewriter.FgYellowColor}
+		}
+	}
+	return colors
+}
+
+// colorize wraps s with the ANSI escape sequences of the given colours.
+func colorize(s string, colors tablewriter.Colors) string {
+	if len(colors) == 0 || s == "" {
+		return s
+	}
+	codes := make([]string, len(colors))
+	for i, c := range colors {
+		codes[i] = strconv.Itoa(c)
+	}
+	return "\033[" + strings.Join(codes, ";") + "m" + s + "\033[0m"
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func TestUseColor(t *testing.T) {
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	for _, tc := range []struct {
+		mode     string
+		file     string
+		noColor  string
+		expected bool
+	}{
+		{ColorAlways, "", "", true},
+		{ColorAlways, "", "1", true},
+		{ColorAlways, "/tmp/report.txt", "", false},
+		{ColorNever, "", "", false},
+		{ColorAuto, "/tmp/report.txt", "", false},
+		{ColorAuto, "", "1", false},
+	} {
+		os.Setenv("NO_COLOR", tc.noColor)
+		if got := useColor(tc.mode, tc.file); got != tc.expected {
+			t.Errorf("mode %s, file %q, NO_COLOR %q: expected %t, got %t", tc.mode, tc.file, tc.noColor, tc.expected, got)
+		}
+	}
+}
+
+func TestValidateThresholds(t *testing.T) {
+	for _, tc := range []struct {
+		thresholds map[string]float64
+		valid      bool
+	}{
+		{nil, true},
+		{map[string]float64{"number_of_time_series": 1e6, "number_of_dropped_targets": 0}, true},
+		{map[string]float64{"number_of_time_series_growth_1d": 10}, true},
+		{map[string]float64{"version": 2}, false},
+		{map[string]float64{"number_of_series": 1e6}, false},
+	} {
+		err := validateThresholds(tc.thresholds)
+		if tc.valid && err != nil {
+			t.Errorf("%v: unexpected error: %s", tc.thresholds, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%v: expected an error", tc.thresholds)
+		}
+	}
+}
+
+func TestRowColors(t *testing.T) {
+	columns, err := parseColumns([]string{"name", "number_of_time_series", "number_of_dropped_targets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thresholds := map[string]float64{"number_of_time_series": 1000, "number_of_dropped_targets": 0}
+	var (
+		none     = tablewriter.Colors(nil)
+		red      = tablewriter.Colors{tablewriter.FgRedColor}
+		boldRed  = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+		degraded = tablewriter.Colors{tablewriter.FgYellowColor}
+	)
+	for _, tc := range []struct {
+		name     string
+		ps       *PromSummary
+		degraded bool
+		expected []tablewriter.Colors
+	}{
+		{
+			name:     "below the thresholds",
+			ps:       &PromSummary{Name: "p1", NumOfTimeSeries: "1000", NumOfDroppedTargets: "0"},
+			expected: []tablewriter.Colors{none, none, none},
+		},
+		{
+			name:     "above a threshold",
+			ps:       &PromSummary{Name: "p1", NumOfTimeSeries: "1001", NumOfDroppedTargets: "0"},
+			degraded: true,
+			expected: []tablewriter.Colors{degraded, boldRed, degraded},
+		},
+		{
+			name:     "above all the thresholds",
+			ps:       &PromSummary{Name: "p1", NumOfTimeSeries: "2000", NumOfDroppedTargets: "3"},
+			degraded: true,
+			expected: []tablewriter.Colors{degraded, boldRed, boldRed},
+		},
+		{
+			name:     "not a number",
+			ps:       &PromSummary{Name: "p1", NumOfTimeSeries: "", NumOfDroppedTargets: "n/a"},
+			expected: []tablewriter.Colors{none, none, none},
+		},
+		{
+			name:     "NotOK",
+			ps:       &PromSummary{Name: "p1", Status: PromStatusNotOK, NumOfTimeSeries: "2000"},
+			expected: []tablewriter.Colors{red, red, red},
+		},
+	} {
+		if got := isDegraded(thresholds, tc.ps); got != tc.degraded {
+			t.Errorf("%s: expected degraded %t, got %t", tc.name, tc.degraded, got)
+		}
+		if got := rowColors(columns, thresholds, tc.ps); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected the colours %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		colors   tablewriter.Colors
+		expected string
+	}{
+		{"p1", nil, "p1"},
+		{"", tablewriter.Colors{tablewriter.FgRedColor}, ""},
+		{"p1", tablewriter.Colors{tablewriter.FgRedColor}, "\033[31mp1\033[0m"},
+		{"p1", tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}, "\033[1;31mp1\033[0m"},
+	} {
+		if got := colorize(tc.s, tc.colors); got != tc.expected {
+			t.Errorf("%q %v: expected %q, got %q", tc.s, tc.colors, tc.expected, got)
+		}
+	}
+}
+
+func TestColorAlwaysFileOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prom-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outCfg := DefaultOutputConfig
+	outCfg.Format, outCfg.File = "table", filepath.Join(dir, "report.txt")
+	out, err := newOutput(outCfg, ColorAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []*PromSummary{{Name: "p1", Status: PromStatusNotOK, Error: "connection refused"}}
+	if err := out.finish(results); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(outCfg.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "\033[") || !strings.Contains(string(content), "connection refused") {
+		t.Errorf("expected a report without escape sequences:\n%q", content)
+	}
+}
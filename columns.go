@@ -0,0 +1,291 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Column is a column of the report.
+type Column struct {
+	// Name is the column key, it is used in the configuration, the flags
+	// and as the field name of the JSON/YAML outputs.
+	Name string
+	// Header is the column header of the tabular outputs.
+	Header string
+	// ShortHeader is the abbreviated header of the compact table.
+	ShortHeader string
+	// Numeric columns are sorted as numbers, and written as numbers
+	// by the formats which support it.
+	Numeric bool
+	value   func(*PromSummary) string
+	// humanize renders a raw value in a human readable way, it is
+	// nil if the column values are already human readable.
+	humanize func(string) string
+	// trend is set for the trend columns, which are computed from the history.
+	trend *trend
+}
+
+// Value returns the column value of the given summary.
+func (c Column) Value(ps *PromSummary) string {
+	return c.value(ps)
+}
+
+// Humanize renders a raw value of the column in a human readable way.
+func (c Column) Humanize(v string) string {
+	if c.humanize == nil || v == "" {
+		return v
+	}
+	return c.humanize(v)
+}
+
+// columns are all the available columns, the first numOfDefaultColumns
+// ones are the default columns, in the default order.
+var columns = []Column{
+	{Name: "name", Header: "name", ShortHeader: "name",
+		value: func(ps *PromSummary) string { return ps.Name }},
+	{Name: "address", Header: "address", ShortHeader: "address",
+		value: func(ps *PromSummary) string { return ps.Address }},
+	{Name: "status", Header: "status", ShortHeader: "status",
+		value: func(ps *PromSummary) string { return ps.Status.String() }},
+	{Name: "error", Header: "error", ShortHeader: "error",
+		value: func(ps *PromSummary) string { return ps.Error }},
+	{Name: "version", Header: "version", ShortHeader: "version",
+		value: func(ps *PromSummary) string { return ps.Version }},
+	{Name: "storage_retention", Header: "storage retention", ShortHeader: "retention",
+		humanize: humanizeRetention,
+		value:    func(ps *PromSummary) string { return ps.StorageRetention }},
+	{Name: "number_of_active_targets", Header: "number of active targets", ShortHeader: "active",
+		Numeric: true, humanize: humanizeNumber,
+		value: func(ps *PromSummary) string { return ps.NumOfActiveTargets }},
+	{Name: "number_of_dropped_targets", Header: "number of dropped targets", ShortHeader: "dropped",
+		Numeric: true, humanize: humanizeNumber,
+		value: func(ps *PromSummary) string { return ps.NumOfDroppedTargets }},
+	{Name: "number_of_time_series", Header: "number of time series", ShortHeader: "series",
+		Numeric: true, humanize: humanizeNumber,
+		value: func(ps *PromSummary) string { return ps.NumOfTimeSeries }},
+	{Name: "number_of_chunks", Header: "number of chunks", ShortHeader: "chunks",
+		Numeric: true, humanize: humanizeNumber,
+		value: func(ps *PromSummary) string { return ps.NumOfChunks }},
+	{Name: "number_of_ingested_samples_per_seconds", Header: "number of ingested samples per seconds",
+		ShortHeader: "samples/s", Numeric: true, humanize: humanizeNumber,
+		value: func(ps *PromSummary) string { return ps.NumOfIngestedSamplesPerSec }},
+	{Name: "collected_at", Header: "collected at", ShortHeader: "collected",
+		value: func(ps *PromSummary) string {
+			if ps.CollectedAt.IsZero() {
+				return ""
+			}
+			return ps.CollectedAt.UTC().Format(time.RFC3339)
+		}},
+}
+
+// numOfDefaultColumns is the number of columns written by default.
+const numOfDefaultColumns = 11
+
+// lookupColumn returns the column with the given name.
+func lookupColumn(name string) (Column, error) {
+	name = strings.TrimSpace(name)
+	for _, c := range columns {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	if c, ok := trendColumn(name); ok {
+		return c, nil
+	}
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, c.Name)
+	}
+	return Column{}, errors.Errorf("unknown column %q, available columns: %s, and the trend columns "+
+		"'<column>_change[_<duration>]' and '<column>_growth[_<duration>]' of the numeric ones",
+		name, strings.Join(names, ", "))
+}
+
+// parseColumns returns the columns with the given names, in the given order.
+// The default columns are returned if no names are given.
+func parseColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		return columns[:numOfDefaultColumns], nil
+	}
+	selected := make([]Column, 0, len(names))
+	for _, name := range names {
+		c, err := lookupColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// defaultColumnNames returns the names of the default columns.
+func defaultColumnNames() []string {
+	names := make([]string, numOfDefaultColumns)
+	for i, c := range columns[:numOfDefaultColumns] {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// parseSort parses a sort specification, '<column>[:asc|:desc]'.
+func parseSort(s string) (Column, bool, error) {
+	name, order := s, "asc"
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		name, order = s[:i], strings.ToLower(s[i+1:])
+	}
+	c, err := lookupColumn(name)
+	if err != nil {
+		return Column{}, false, err
+	}
+	switch order {
+	case "asc":
+		return c, false, nil
+	case "desc":
+		return c, true, nil
+	default:
+		return Column{}, false, errors.Errorf("unknown sort order %q, must be 'asc' or 'desc'", order)
+	}
+}
+
+// sortResults sorts the results in place by the given column. Numeric columns
+// are compared as numbers, the values which are not numbers are always last.
+func sortResults(results []*PromSummary, by Column, desc bool) {
+	less := func(a, b *PromSummary) bool {
+		va, vb := by.Value(a), by.Value(b)
+		if by.Numeric {
+			fa, erra := strconv.ParseFloat(va, 64)
+			fb, errb := strconv.ParseFloat(vb, 64)
+			switch {
+			case erra != nil || errb != nil:
+				return erra == nil && errb != nil
+			case desc:
+				return fa > fb
+			default:
+				return fa < fb
+			}
+		}
+		if desc {
+			return va > vb
+		}
+		return va < vb
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return less(results[i], results[j])
+	})
+}
+
+// headers returns the headers of the given columns.
+func headers(columns []Column) []string {
+	h := make([]string, len(columns))
+	for i, c := range columns {
+		h[i] = c.Header
+	}
+	return h
+}
+
+// shortHeaders returns the abbreviated headers of the given columns.
+func shortHeaders(columns []Column) []string {
+	h := make([]string, len(columns))
+	for i, c := range columns {
+		h[i] = c.ShortHeader
+	}
+	return h
+}
+
+// row returns the values of the given columns, humanized if asked.
+func row(columns []Column, ps *PromSummary, humanize bool) []string {
+	r := make([]string, len(columns))
+	for i, c := range columns {
+		r[i] = c.Value(ps)
+		if humanize {
+			r[i] = c.Humanize(r[i])
+		}
+	}
+	return r
+}
+
+// filteredRecord is a summary restricted to the selected columns. It keeps
+// the column order and the field types of PromSummary when it is marshalled.
+type filteredRecord struct {
+	columns []Column
+	summary *PromSummary
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (r filteredRecord) MarshalJSON() ([]byte, error) {
+	content, err := json.Marshal(r.summary)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i, c := range r.columns {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(c.Name)
+		b.Write(key)
+		b.WriteString(":")
+		value, ok := fields[c.Name]
+		if !ok {
+			value, _ = json.Marshal(c.Value(r.summary))
+		}
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (r filteredRecord) MarshalYAML() (interface{}, error) {
+	content, err := yaml.Marshal(r.summary)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	out := make(yaml.MapSlice, 0, len(r.columns))
+	for _, c := range r.columns {
+		value, ok := fields[c.Name]
+		if !ok {
+			value = c.Value(r.summary)
+		}
+		out = append(out, yaml.MapItem{Key: c.Name, Value: value})
+	}
+	return out, nil
+}
+
+// filterRecords restricts the results to the given columns.
+func filterRecords(columns []Column, results []*PromSummary) []filteredRecord {
+	records := make([]filteredRecord, len(results))
+	for i, ps := range results {
+		records[i] = filteredRecord{columns: columns, summary: ps}
+	}
+	return records
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		column string
+		desc   bool
+		err    bool
+	}{
+		{"name", "name", false, false},
+		{"name:asc", "name", false, false},
+		{"number_of_time_series:desc", "number_of_time_series", true, false},
+		{"number_of_time_series:DESC", "number_of_time_series", true, false},
+		{" version :desc", "version", true, false},
+		{"number_of_time_series_change_1d:desc", "number_of_time_series_change_1d", true, false},
+		{"name:up", "", false, true},
+		{"unknown", "", false, true},
+		{"unknown:desc", "", false, true},
+		{"", "", false, true},
+	} {
+		c, desc, err := parseSort(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tc.in, err)
+			continue
+		}
+		if c.Name != tc.column || desc != tc.desc {
+			t.Errorf("%q: expected %s desc=%t, got %s desc=%t", tc.in, tc.column, tc.desc, c.Name, desc)
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	for _, tc := range []struct {
+		in       []string
+		expected []string
+		err      bool
+	}{
+		{nil, defaultColumnNames(), false},
+		{[]string{"status", "name"}, []string{"status", "name"}, false},
+		{[]string{"collected_at"}, []string{"collected_at"}, false},
+		{[]string{"name", "nmae"}, nil, true},
+	} {
+		cols, err := parseColumns(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v: expected an error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", tc.in, err)
+			continue
+		}
+		names := make([]string, len(cols))
+		for i, c := range cols {
+			names[i] = c.Name
+		}
+		if strings.Join(names, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%v: expected %v, got %v", tc.in, tc.expected, names)
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	results := []*PromSummary{
+		{Name: "b", NumOfTimeSeries: "900"},
+		{Name: "a", NumOfTimeSeries: ""},
+		{Name: "c", NumOfTimeSeries: "1000"},
+		{Name: "d", NumOfTimeSeries: "80"},
+	}
+	for _, tc := range []struct {
+		sort     string
+		expected string
+	}{
+		{"name", "a,b,c,d"},
+		{"name:desc", "d,c,b,a"},
+		// The numbers are not compared as strings, and the missing
+		// values are always last.
+		{"number_of_time_series", "d,b,c,a"},
+		{"number_of_time_series:desc", "c,b,d,a"},
+	} {
+		by, desc, err := parseSort(tc.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sorted := make([]*PromSummary, len(results))
+		copy(sorted, results)
+		sortResults(sorted, by, desc)
+		names := make([]string, len(sorted))
+		for i, ps := range sorted {
+			names[i] = ps.Name
+		}
+		if got := strings.Join(names, ","); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.sort, tc.expected, got)
+		}
+	}
+}
+
+func TestFilteredRecord(t *testing.T) {
+	cols, err := parseColumns([]string{"status", "name", "number_of_time_series"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &PromSummary{Name: "p1", Status: PromStatusOK, NumOfTimeSeries: "1000", Version: "2.25.0"}
+	content, err := json.Marshal(filteredRecord{columns: cols, summary: ps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The column order is kept, and the other fields are left out.
+	if !strings.HasPrefix(string(content), `{"status":`) || !strings.Contains(string(content), `"name":"p1"`) ||
+		strings.Contains(string(content), "2.25.0") {
+		t.Errorf("unexpected record %s", content)
+	}
+}
@@ -17,31 +17,208 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
+	"github.com/alecthomas/units"
+	"github.com/prometheus/common/model"
 	"gopkg.in/yaml.v2"
 )
 
 // Config is the top-level configuration
 type Config struct {
 	PrometheusConfigs map[string]PrometheusConfig `yaml:"prometheus_configs"`
-	OutputConfig      OutputConfig                `yaml:"output_config"`
+	// OutputConfigs are the outputs written on every run. A single
+	// output object is accepted as well as a list of outputs.
+	OutputConfigs OutputConfigs `yaml:"output_config"`
+	HistoryConfig HistoryConfig `yaml:"history_config"`
+	// NotificationConfigs are the chat notifications sent on every run.
+	NotificationConfigs []NotificationConfig `yaml:"notification_configs,omitempty"`
+	// PushgatewayConfig pushes the summary metrics on every run.
+	PushgatewayConfig *PushgatewayConfig `yaml:"pushgateway_config,omitempty"`
+	// RemoteWriteConfigs write the summary metrics on every run.
+	RemoteWriteConfigs []RemoteWriteConfig `yaml:"remote_write_configs,omitempty"`
 }
 
+// RemoteWriteConfig defines a remote write endpoint the summary metrics
+// are written to, a Prometheus-compatible long-term storage.
+type RemoteWriteConfig struct {
+	// ExternalLabels are added to all the series.
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+	// Webhook is the remote write endpoint, the method is always POST.
+	Webhook WebhookConfig `yaml:",inline"`
+}
+
+// PushgatewayConfig defines the Pushgateway the summary metrics are pushed to.
+type PushgatewayConfig struct {
+	URL string `yaml:"url"`
+	// Job is the job label of the pushed metrics, 'prom_summary' by default.
+	Job string `yaml:"job"`
+	// Grouping are the other labels of the grouping key.
+	Grouping  map[string]string `yaml:"grouping,omitempty"`
+	BasicAuth BasicAuth         `yaml:"basic_auth,omitempty"`
+	// Timeout is the timeout of the push, 10s by default.
+	Timeout model.Duration `yaml:"timeout"`
+}
+
+// NotificationConfig defines a chat notification, a short fleet summary
+// sent to an incoming webhook.
+type NotificationConfig struct {
+	// Type is the message format: 'slack', 'mattermost' or 'teams'.
+	Type string `yaml:"type"`
+	// Channel and Username override the ones of the Slack and
+	// Mattermost incoming webhook.
+	Channel  string `yaml:"channel,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	// OnChangeOrFailure only sends the notification if an instance is
+	// NotOK, or if an instance status has changed since the last run.
+	// It requires history_config.path.
+	OnChangeOrFailure bool `yaml:"on_change_or_failure,omitempty"`
+	// Webhook is the incoming webhook the message is sent to.
+	Webhook WebhookConfig `yaml:",inline"`
+}
+
+// HistoryConfig defines the local history of the collected summaries.
+type HistoryConfig struct {
+	// Path is the history database file path. If it is set, the summaries
+	// collected on every run are appended to it, and they can be queried
+	// with the 'history' command.
+	Path string `yaml:"path"`
+}
+
+// OutputConfigs is a list of output configurations.
+type OutputConfigs []OutputConfig
+
 // OutputConfig defines output related configurations.
 type OutputConfig struct {
-	// Format is output format, 'table', 'json', 'csv', 'json'
-	// 'table' by default.
+	// Format is output format, 'table', 'json', 'yaml', 'csv', 'junit',
+	// 'ndjson', 'xlsx', 'markdown', 'html', 'table-compact', 'table-vertical',
+	// 'prometheus', 'table' by default.
 	Format string `yaml:"format"`
 	// File is the output file path, by default, Prom-summary will
 	// return output to stdout. If this field is specified,
 	// the output will be written to file instead.
+	// The path may contain the placeholders {{ .Date }}, {{ .Time }},
+	// {{ .Timestamp }}, {{ .Format }}, {{ .Hostname }} and strftime
+	// directives such as %Y-%m-%d, the missing directories are created.
 	File string `yaml:"file"`
+	// KeepLast is the number of report files to keep, the older ones
+	// matching the file path are removed. 0 keeps all of them.
+	KeepLast int `yaml:"keep_last,omitempty"`
+	// MaxAge is the maximum age of the report files matching the
+	// file path, the older ones are removed. 0 keeps all of them.
+	MaxAge model.Duration `yaml:"max_age,omitempty"`
+	// Columns is the list of columns to output, in order.
+	// All the columns but 'collected_at' by default.
+	Columns []string `yaml:"columns,omitempty"`
+	// SortBy is the column the results are sorted by, optionally
+	// followed by the order ':asc' or ':desc',
+	// for example 'number_of_time_series:desc'.
+	// 'name' by default.
+	SortBy string `yaml:"sort_by"`
+	// Totals adds the fleet totals: a totals row to the 'table', 'csv',
+	// 'markdown' and 'html' formats, a 'totals' object to 'json' and 'yaml'.
+	Totals bool `yaml:"totals,omitempty"`
+	// Humanize renders the numbers with SI suffixes (2.4M), the sizes with
+	// binary units (10 GiB) and the durations in words (15 days). It only
+	// applies to the 'table', 'markdown' and 'html' formats, the machine
+	// readable formats always keep raw values.
+	Humanize bool `yaml:"humanize,omitempty"`
+	// Thresholds are the limits of numeric columns, by column name. When
+	// the table is colourised, the cells above their limit are highlighted
+	// and their rows are marked as degraded.
+	Thresholds map[string]float64 `yaml:"thresholds,omitempty"`
+	// Webhook sends the report to a HTTP endpoint after each run. The
+	// report is not written to stdout when it is set, but it is still
+	// written to the file if one is configured.
+	Webhook *WebhookConfig `yaml:"webhook,omitempty"`
+	// Email sends the report by email after each run, the format must be
+	// 'html' or 'markdown'. Like the webhook, the report is not written to
+	// stdout when it is set.
+	Email *EmailConfig `yaml:"email,omitempty"`
+	// S3 uploads the report to an S3-compatible object storage after each
+	// run. Like the webhook, the report is not written to stdout when it is set.
+	S3 *S3Config `yaml:"s3,omitempty"`
+}
+
+// S3Config defines the S3-compatible bucket the report is uploaded to.
+type S3Config struct {
+	// Endpoint is the object storage URL, for example
+	// 'https://minio.example.com:9000'. It is the AWS S3 endpoint
+	// of the region by default.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Region is the bucket region, 'us-east-1' by default.
+	Region string `yaml:"region"`
+	Bucket string `yaml:"bucket"`
+	// Key is the object key, it supports the placeholders and the
+	// strftime directives of the output file path,
+	// 'prom-summary/%Y/%m/%d/%H%M%S.{{ .Format }}' by default.
+	Key             string `yaml:"key"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token,omitempty"`
+	// PathStyle uses path-style URLs, 'endpoint/bucket/key', instead of
+	// virtual-hosted ones, as required by MinIO.
+	PathStyle bool `yaml:"path_style,omitempty"`
+	// PartSize is the part size of multipart uploads, the reports larger
+	// than a part are uploaded in parts. 5MiB, the minimum, by default.
+	PartSize units.Base2Bytes `yaml:"part_size"`
+	// Metadata is added to the object metadata, along with the run id,
+	// the number of instances and the number of NotOK instances.
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	// Timeout is the timeout of every request, 1m by default.
+	Timeout model.Duration `yaml:"timeout"`
+}
+
+// EmailConfig defines the email the report is sent with.
+type EmailConfig struct {
+	// Smarthost is the SMTP server, 'host:port'. The port 465 uses
+	// implicit TLS, the other ports use STARTTLS.
+	Smarthost string   `yaml:"smarthost"`
+	From      string   `yaml:"from"`
+	To        []string `yaml:"to"`
+	// Subject is a template, with the placeholders {{ .Instances }},
+	// {{ .OK }}, {{ .NotOK }}, {{ .Date }} and {{ .Hostname }}.
+	Subject      string `yaml:"subject"`
+	AuthUsername string `yaml:"auth_username,omitempty"`
+	AuthPassword string `yaml:"auth_password,omitempty"`
+	AuthIdentity string `yaml:"auth_identity,omitempty"`
+	// RequireTLS fails if the server does not support STARTTLS,
+	// true by default.
+	RequireTLS            bool `yaml:"require_tls"`
+	TLSInsecureSkipVerify bool `yaml:"tls_insecure_skip_verify,omitempty"`
+	// AttachCSV attaches the report as CSV, true by default.
+	AttachCSV bool `yaml:"attach_csv"`
+	// Timeout is the timeout of the SMTP connection, 30s by default.
+	Timeout model.Duration `yaml:"timeout"`
+}
+
+// WebhookConfig defines the HTTP endpoint the report is sent to.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Method is the HTTP method, 'POST' by default.
+	Method string `yaml:"method"`
+	// Headers are added to the request, the Content-Type header is
+	// set from the output format unless it is configured here.
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	BasicAuth   BasicAuth         `yaml:"basic_auth,omitempty"`
+	BearerToken string            `yaml:"bearer_token,omitempty"`
+	// Timeout is the timeout of every attempt, 10s by default.
+	Timeout model.Duration `yaml:"timeout"`
+	// MaxRetries is the number of retries on network errors and on
+	// 5xx and 429 responses, 3 by default.
+	MaxRetries int `yaml:"max_retries"`
+	// RetryInterval is the wait before the first retry, it is doubled
+	// after every retry, 1s by default.
+	RetryInterval model.Duration `yaml:"retry_interval"`
 }
 
 // PrometheusConfig is the Prometheus instance config.
 type PrometheusConfig struct {
 	Address   string    `yaml:"address"`
 	BasicAuth BasicAuth `yaml:"basic_auth"`
+	// Labels are added to the series of the instance written
+	// with remote write, the dashboard can filter the instances by label.
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 // BasicAuth stores authentication (username, password) configuration.
@@ -55,11 +232,52 @@ var (
 	// By default, print the output to stdout/stderr with format table.
 	DefaultOutputConfig = OutputConfig{
 		Format: "csv",
+		SortBy: "name",
+	}
+
+	// DefaultWebhookConfig is the default webhook configuration.
+	DefaultWebhookConfig = WebhookConfig{
+		Method:        "POST",
+		Timeout:       model.Duration(10 * time.Second),
+		MaxRetries:    3,
+		RetryInterval: model.Duration(time.Second),
+	}
+
+	// DefaultEmailConfig is the default email configuration.
+	DefaultEmailConfig = EmailConfig{
+		Subject:    "Prometheus summary: {{ .NotOK }} NotOK out of {{ .Instances }} instances",
+		RequireTLS: true,
+		AttachCSV:  true,
+		Timeout:    model.Duration(30 * time.Second),
+	}
+
+	// DefaultS3Config is the default S3 configuration.
+	DefaultS3Config = S3Config{
+		Region:   "us-east-1",
+		Key:      "prom-summary/%Y/%m/%d/%H%M%S.{{ .Format }}",
+		PartSize: 5 * units.MiB,
+		Timeout:  model.Duration(time.Minute),
+	}
+
+	// DefaultPushgatewayConfig is the default Pushgateway configuration.
+	DefaultPushgatewayConfig = PushgatewayConfig{
+		Job:     "prom_summary",
+		Timeout: model.Duration(10 * time.Second),
+	}
+
+	// DefaultRemoteWriteConfig is the default remote write configuration.
+	DefaultRemoteWriteConfig = RemoteWriteConfig{
+		Webhook: DefaultWebhookConfig,
+	}
+
+	// DefaultNotificationConfig is the default notification configuration.
+	DefaultNotificationConfig = NotificationConfig{
+		Webhook: DefaultWebhookConfig,
 	}
 
 	// DefaultConfig is the default top-level configuration.
 	DefaultConfig = Config{
-		OutputConfig: DefaultOutputConfig,
+		OutputConfigs: OutputConfigs{DefaultOutputConfig},
 	}
 )
 
@@ -76,6 +294,101 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+// It accepts either a list of outputs or a single output object.
+func (c *OutputConfigs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// The form is decided once, so that the error of the matching form is
+	// returned.
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	if _, ok := raw.([]interface{}); ok {
+		var list []OutputConfig
+		if err := unmarshal(&list); err != nil {
+			return err
+		}
+		*c = list
+		return nil
+	}
+	var single OutputConfig
+	if err := unmarshal(&single); err != nil {
+		return err
+	}
+	*c = OutputConfigs{single}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *OutputConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultOutputConfig
+	type plain OutputConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *WebhookConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultWebhookConfig
+	type plain WebhookConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *EmailConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultEmailConfig
+	type plain EmailConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *S3Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultS3Config
+	type plain S3Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *PushgatewayConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultPushgatewayConfig
+	type plain PushgatewayConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *RemoteWriteConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRemoteWriteConfig
+	type plain RemoteWriteConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (c *NotificationConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultNotificationConfig
+	type plain NotificationConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return nil
+}
+
 // String represents Configuration instance as string.
 func (c *Config) String() string {
 	b, err := yaml.Marshal(c)
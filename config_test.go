@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadOutputConfigs(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  string
+		formats []string
+		err     string
+	}{
+		{
+			name:    "object",
+			config:  "output_config:\n  format: csv\n",
+			formats: []string{"csv"},
+		},
+		{
+			name:    "list",
+			config:  "output_config:\n- format: csv\n- format: json\n  columns: [name]\n",
+			formats: []string{"csv", "json"},
+		},
+		{
+			name:   "unknown field in a list",
+			config: "output_config:\n- format: csv\n  colums: [name]\n",
+			err:    "field colums not found",
+		},
+		{
+			name:   "unknown field in an object",
+			config: "output_config:\n  format: csv\n  colums: [name]\n",
+			err:    "field colums not found",
+		},
+		{
+			name:   "invalid type in a list",
+			config: "output_config:\n- format: [csv]\n",
+			err:    "cannot unmarshal !!seq into string",
+		},
+		{
+			name:   "scalar",
+			config: "output_config: csv\n",
+			err:    "cannot unmarshal !!str",
+		},
+	} {
+		cfg, err := Load(tc.config)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+			continue
+		}
+		if len(cfg.OutputConfigs) != len(tc.formats) {
+			t.Errorf("%s: expected %d outputs, got %d", tc.name, len(tc.formats), len(cfg.OutputConfigs))
+			continue
+		}
+		for i, format := range tc.formats {
+			if cfg.OutputConfigs[i].Format != format {
+				t.Errorf("%s: expected the format %q, got %q", tc.name, format, cfg.OutputConfigs[i].Format)
+			}
+			// The defaults are applied to every output.
+			if cfg.OutputConfigs[i].SortBy != DefaultOutputConfig.SortBy {
+				t.Errorf("%s: expected the default sort_by, got %q", tc.name, cfg.OutputConfigs[i].SortBy)
+			}
+		}
+	}
+}
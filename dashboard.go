@@ -0,0 +1,324 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The dashboard assets are compiled into the binary, the dashboard works
+// without any file next to it.
+
+const dashboardStyle = `body { font-family: sans-serif; font-size: 14px; margin: 16px 24px; color: #222; }
+a { color: #1f5fa8; text-decoration: none; }
+a:hover { text-decoration: underline; }
+header { display: flex; align-items: baseline; justify-content: space-between; }
+header h1 { font-size: 20px; margin: 0 0 12px 0; }
+.muted { color: #777; }
+form.filters { margin: 8px 0 12px 0; }
+form.filters label { margin-right: 12px; }
+.counts span { margin-right: 16px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th { background: #eee; }
+td.text, th.text { text-align: left; }
+tr.notok td { background: #ffc7ce; }
+.label { display: inline-block; background: #e8eef7; border-radius: 3px; padding: 0 4px; margin: 1px 2px; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 4px 16px; }
+dt { font-weight: bold; }
+dd { margin: 0; }
+p.error { color: #9c0006; }
+`
+
+const dashboardLayout = `{{ define "header" }}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<link rel="stylesheet" href="/static/style.css">
+</head>
+<body>
+<header>
+<h1><a href="/">Prometheus Summary</a>{{ if .Instance }} / {{ .Instance }}{{ end }}</h1>
+<span class="muted">{{ if .CollectedAt.IsZero }}not collected yet{{ else }}last collection {{ formatTime .CollectedAt }}{{ end }}</span>
+</header>
+{{ end }}
+{{ define "footer" }}</body>
+</html>
+{{ end }}
+{{ define "labels" }}{{ range $k, $v := . }}<span class="label">{{ $k }}={{ $v }}</span>{{ end }}{{ end }}
+`
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"formatTime": formatTime,
+}).Parse(dashboardLayout + `{{ template "header" . }}
+<form class="filters" method="get" action="/">
+<label>Status <select name="status" onchange="this.form.submit()">
+<option value="">all</option>
+{{- range .Statuses }}<option{{ if eq . $.Filter.Status }} selected{{ end }}>{{ . }}</option>{{ end }}
+</select></label>
+<label>Version <select name="version" onchange="this.form.submit()">
+<option value="">all</option>
+{{- range .Versions }}<option{{ if eq . $.Filter.Version }} selected{{ end }}>{{ . }}</option>{{ end }}
+</select></label>
+<label>Label <select name="label" onchange="this.form.submit()">
+<option value="">all</option>
+{{- range .Labels }}<option{{ if eq . $.Filter.Label }} selected{{ end }}>{{ . }}</option>{{ end }}
+</select></label>
+<noscript><button type="submit">Filter</button></noscript>
+{{ if .Filtered }}<a href="/">clear</a>{{ end }}
+</form>
+<p class="counts"><span>{{ len .Rows }} of {{ .Total }} instances</span><span>{{ .NotOK }} NotOK</span></p>
+<table>
+<thead>
+<tr>{{ range $i, $h := .Headers }}<th{{ if lt $i 4 }} class="text"{{ end }}>{{ $h }}</th>{{ end }}<th class="text">labels</th></tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr{{ if not .OK }} class="notok"{{ end }}>
+{{- range $i, $c := .Cells }}{{ if eq $i 0 }}<td class="text"><a href="{{ $.InstancePath }}{{ $c }}">{{ $c }}</a></td>{{ else if lt $i 4 }}<td class="text">{{ $c }}</td>{{ else }}<td>{{ $c }}</td>{{ end }}{{ end -}}
+<td class="text">{{ template "labels" .Labels }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+{{ template "footer" . }}`))
+
+var instanceTemplate = template.Must(template.New("instance").Funcs(template.FuncMap{
+	"formatTime": formatTime,
+}).Parse(dashboardLayout + `{{ template "header" . }}
+{{- with .Summary }}
+{{ if .Error }}<p class="error">{{ .Error }}</p>{{ end }}
+<dl>
+{{- range $.Fields }}
+<dt>{{ .Name }}</dt><dd>{{ .Value }}</dd>
+{{- end }}
+{{- if .Labels }}
+<dt>labels</dt><dd>{{ template "labels" .Labels }}</dd>
+{{- end }}
+</dl>
+{{- with .Details }}
+<h2>Targets by job</h2>
+<table>
+<thead><tr><th class="text">job</th><th>up</th><th>down</th><th>unknown</th></tr></thead>
+<tbody>
+{{- range .TargetsByJob }}
+<tr{{ if .Down }} class="notok"{{ end }}><td class="text">{{ .Job }}</td><td>{{ .Up }}</td><td>{{ .Down }}</td><td>{{ .Unknown }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+<h2>Top metrics</h2>
+<table>
+<thead><tr><th class="text">metric</th><th>series</th></tr></thead>
+<tbody>
+{{- range .TopMetrics }}
+<tr><td class="text">{{ .Name }}</td><td>{{ .Series }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+{{- end }}
+{{- end }}
+{{ template "footer" . }}`))
+
+// dashboardPath is the path prefix of the instance pages.
+const dashboardPath = "/instances/"
+
+// dashboardColumns are the columns of the fleet table, the first one
+// must be the name, the instance pages are linked from it.
+var dashboardColumns, _ = parseColumns([]string{"name", "address", "status", "version",
+	"storage_retention", "number_of_active_targets", "number_of_dropped_targets",
+	"number_of_time_series", "number_of_chunks", "number_of_ingested_samples_per_seconds", "collected_at"})
+
+// dashboardFilter are the filters of the fleet table, the empty ones
+// match every instance. Label is a 'name=value' pair.
+type dashboardFilter struct {
+	Status  string
+	Version string
+	Label   string
+}
+
+func (f dashboardFilter) match(ps *PromSummary) bool {
+	if f.Status != "" && ps.Status.String() != f.Status {
+		return false
+	}
+	if f.Version != "" && ps.Version != f.Version {
+		return false
+	}
+	if f.Label != "" {
+		kv := strings.SplitN(f.Label, "=", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		if v, ok := ps.Labels[kv[0]]; !ok || v != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
+type dashboardRow struct {
+	OK     bool
+	Cells  []string
+	Labels map[string]string
+}
+
+// dashboardPage is the data of the fleet table page.
+type dashboardPage struct {
+	Title        string
+	Instance     string
+	CollectedAt  time.Time
+	InstancePath string
+	Filter       dashboardFilter
+	Filtered     bool
+	Statuses     []string
+	Versions     []string
+	Labels       []string
+	Headers      []string
+	Rows         []dashboardRow
+	Total        int
+	NotOK        int
+}
+
+type dashboardField struct {
+	Name, Value string
+}
+
+// instancePage is the data of the instance page.
+type instancePage struct {
+	Title       string
+	Instance    string
+	CollectedAt time.Time
+	Summary     *PromSummary
+	Fields      []dashboardField
+}
+
+// dashboard serves the HTML pages of the last summaries: the fleet table,
+// which can be filtered by status, version and label, and a page per
+// instance with its detail sections.
+type dashboard struct {
+	cache *summaryCache
+}
+
+// handleIndex serves the fleet table, the filters are the 'status',
+// 'version' and 'label' query parameters.
+func (d *dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	results, collectedAt, _ := d.cache.summaries()
+	query := r.URL.Query()
+	page := dashboardPage{
+		Title:        "Prometheus Summary",
+		CollectedAt:  collectedAt,
+		InstancePath: dashboardPath,
+		Filter: dashboardFilter{
+			Status:  query.Get("status"),
+			Version: query.Get("version"),
+			Label:   query.Get("label"),
+		},
+		Statuses: []string{PromStatusOK.String(), PromStatusNotOK.String()},
+		Headers:  headers(dashboardColumns),
+		Total:    len(results),
+	}
+	page.Filtered = page.Filter != dashboardFilter{}
+
+	versions := make(map[string]bool)
+	labels := make(map[string]bool)
+	for _, ps := range results {
+		if ps.Version != "" {
+			versions[ps.Version] = true
+		}
+		for k, v := range ps.Labels {
+			labels[k+"="+v] = true
+		}
+		if !page.Filter.match(ps) {
+			continue
+		}
+		if ps.Status != PromStatusOK {
+			page.NotOK++
+		}
+		page.Rows = append(page.Rows, dashboardRow{
+			OK:     ps.Status == PromStatusOK,
+			Cells:  row(dashboardColumns, ps, true),
+			Labels: ps.Labels,
+		})
+	}
+	page.Versions = sortedKeys(versions)
+	page.Labels = sortedKeys(labels)
+	writeHTMLPage(w, http.StatusOK, dashboardTemplate, page)
+}
+
+// handleInstance serves the page of an instance, /instances/{name}.
+func (d *dashboard) handleInstance(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, dashboardPath))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	results, collectedAt, _ := d.cache.summaries()
+	for _, ps := range results {
+		if ps.Name != name {
+			continue
+		}
+		page := instancePage{
+			Title:       name + " - Prometheus Summary",
+			Instance:    name,
+			CollectedAt: collectedAt,
+			Summary:     ps,
+		}
+		cells := row(dashboardColumns, ps, true)
+		for i, c := range dashboardColumns[1:] {
+			page.Fields = append(page.Fields, dashboardField{Name: c.Header, Value: cells[i+1]})
+		}
+		writeHTMLPage(w, http.StatusOK, instanceTemplate, page)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// handleStyle serves the dashboard stylesheet.
+func (d *dashboard) handleStyle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write([]byte(dashboardStyle))
+}
+
+func writeHTMLPage(w http.ResponseWriter, status int, tmpl *template.Template, data interface{}) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(b.String()))
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Changes of a Prometheus instance between two reports.
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "removed"
+	ChangeChanged   = "changed"
+	ChangeUnchanged = "unchanged"
+)
+
+// diffFormats are the supported output formats of the diff command.
+var diffFormats = []string{"table", "markdown", "json"}
+
+// InstanceDiff are the changes of a Prometheus instance between two reports.
+type InstanceDiff struct {
+	Name                string        `json:"name"`
+	Change              string        `json:"change"`
+	Status              *StringChange `json:"status,omitempty"`
+	Version             *StringChange `json:"version,omitempty"`
+	StorageRetention    *StringChange `json:"storage_retention,omitempty"`
+	NumOfActiveTargets  *NumberChange `json:"number_of_active_targets,omitempty"`
+	NumOfDroppedTargets *NumberChange `json:"number_of_dropped_targets,omitempty"`
+	NumOfTimeSeries     *NumberChange `json:"number_of_time_series,omitempty"`
+	NumOfChunks         *NumberChange `json:"number_of_chunks,omitempty"`
+}
+
+// StringChange is the old and new value of a field which has changed.
+type StringChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+func (c *StringChange) String() string {
+	return fmt.Sprintf("%s -> %s", c.Old, c.New)
+}
+
+// NumberChange is the old and new value of a numeric field which has changed.
+// Percent is nil if the old value is 0.
+type NumberChange struct {
+	Old     float64  `json:"old"`
+	New     float64  `json:"new"`
+	Delta   float64  `json:"delta"`
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+func (c *NumberChange) String() string {
+	sign := "+"
+	if c.Delta < 0 {
+		sign = "-"
+	}
+	s := fmt.Sprintf("%s -> %s (%s%s", trimDecimals(c.Old, 2), trimDecimals(c.New, 2),
+		sign, trimDecimals(math.Abs(c.Delta), 2))
+	if c.Percent != nil {
+		s += fmt.Sprintf(", %+.1f%%", *c.Percent)
+	}
+	return s + ")"
+}
+
+// readReport reads a JSON report produced by prom-summary: a 'json' report,
+// with or without totals, or a 'ndjson' one. The fields which have not been
+// selected in the report are left empty.
+func readReport(filename string) ([]*PromSummary, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var results []*PromSummary
+	if err := json.Unmarshal(content, &results); err == nil {
+		return results, nil
+	}
+	var withTotals struct {
+		Results []*PromSummary `json:"results"`
+	}
+	if err := json.Unmarshal(content, &withTotals); err == nil && withTotals.Results != nil {
+		return withTotals.Results, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	for {
+		var record PromSummary
+		if err := dec.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a JSON report", filename)
+		}
+		results = append(results, &record)
+	}
+	return results, nil
+}
+
+// diffReports compares two reports, the instances are matched by name
+// and sorted by name.
+func diffReports(oldResults, newResults []*PromSummary) []*InstanceDiff {
+	oldByName := make(map[string]*PromSummary, len(oldResults))
+	for _, ps := range oldResults {
+		oldByName[ps.Name] = ps
+	}
+	newByName := make(map[string]*PromSummary, len(newResults))
+	for _, ps := range newResults {
+		newByName[ps.Name] = ps
+	}
+
+	var diffs []*InstanceDiff
+	for _, ps := range newResults {
+		old, ok := oldByName[ps.Name]
+		if !ok {
+			diffs = append(diffs, &InstanceDiff{Name: ps.Name, Change: ChangeAdded})
+			continue
+		}
+		diffs = append(diffs, diffInstance(old, ps))
+	}
+	for _, ps := range oldResults {
+		if _, ok := newByName[ps.Name]; !ok {
+			diffs = append(diffs, &InstanceDiff{Name: ps.Name, Change: ChangeRemoved})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+func diffInstance(old, new *PromSummary) *InstanceDiff {
+	d := &InstanceDiff{
+		Name:                new.Name,
+		Status:              diffString(old.Status.String(), new.Status.String()),
+		Version:             diffString(old.Version, new.Version),
+		StorageRetention:    diffString(old.StorageRetention, new.StorageRetention),
+		NumOfActiveTargets:  diffNumber(old.NumOfActiveTargets, new.NumOfActiveTargets),
+		NumOfDroppedTargets: diffNumber(old.NumOfDroppedTargets, new.NumOfDroppedTargets),
+		NumOfTimeSeries:     diffNumber(old.NumOfTimeSeries, new.NumOfTimeSeries),
+		NumOfChunks:         diffNumber(old.NumOfChunks, new.NumOfChunks),
+	}
+	d.Change = ChangeUnchanged
+	if d.Status != nil || d.Version != nil || d.StorageRetention != nil ||
+		d.NumOfActiveTargets != nil || d.NumOfDroppedTargets != nil ||
+		d.NumOfTimeSeries != nil || d.NumOfChunks != nil {
+		d.Change = ChangeChanged
+	}
+	return d
+}
+
+func diffString(old, new string) *StringChange {
+	if old == new {
+		return nil
+	}
+	return &StringChange{Old: old, New: new}
+}
+
+// diffNumber returns nil if the values are equal, or if one of them is
+// not a number, for example when the instance was NotOK.
+func diffNumber(old, new string) *NumberChange {
+	o, err := strconv.ParseFloat(old, 64)
+	if err != nil {
+		return nil
+	}
+	n, err := strconv.ParseFloat(new, 64)
+	if err != nil || o == n {
+		return nil
+	}
+	c := &NumberChange{Old: o, New: n, Delta: n - o}
+	if o != 0 {
+		p := (n - o) / o * 100
+		c.Percent = &p
+	}
+	return c
+}
+
+// writeDiff writes the diffs in one of diffFormats. If changedOnly is true,
+// the unchanged instances are left out.
+func writeDiff(w io.Writer, format string, diffs []*InstanceDiff, changedOnly bool) error {
+	if changedOnly {
+		var changed []*InstanceDiff
+		for _, d := range diffs {
+			if d.Change != ChangeUnchanged {
+				changed = append(changed, d)
+			}
+		}
+		diffs = changed
+	}
+
+	switch format {
+	case "json":
+		if diffs == nil {
+			diffs = []*InstanceDiff{}
+		}
+		content, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(content))
+		return err
+	case "table", "markdown":
+		table := newTable(w, format)
+		table.SetHeader([]string{
+			"name", "change", "status", "version", "storage retention",
+			"number of active targets", "number of dropped targets",
+			"number of time series", "number of chunks",
+		})
+		for _, d := range diffs {
+			table.Append([]string{
+				d.Name, d.Change, changeString(d.Status), changeString(d.Version),
+				changeString(d.StorageRetention), changeString(d.NumOfActiveTargets),
+				changeString(d.NumOfDroppedTargets), changeString(d.NumOfTimeSeries),
+				changeString(d.NumOfChunks),
+			})
+		}
+		table.Render()
+		return nil
+	default:
+		return errors.Errorf("unsupported diff format %q", format)
+	}
+}
+
+// changeString renders a change, it is empty if there is no change.
+func changeString(c fmt.Stringer) string {
+	switch v := c.(type) {
+	case *StringChange:
+		if v == nil {
+			return ""
+		}
+	case *NumberChange:
+		if v == nil {
+			return ""
+		}
+	}
+	return c.String()
+}
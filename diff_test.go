@@ -0,0 +1,185 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffReports(t *testing.T) {
+	oldResults := []*PromSummary{
+		{Name: "same", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1000"},
+		{Name: "upgraded", Status: PromStatusOK, Version: "2.24.0", NumOfTimeSeries: "1000"},
+		{Name: "down", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1000"},
+		{Name: "removed", Status: PromStatusOK},
+		{Name: "empty", Status: PromStatusOK, NumOfTimeSeries: "0"},
+	}
+	newResults := []*PromSummary{
+		{Name: "same", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1000"},
+		{Name: "upgraded", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1500"},
+		{Name: "down", Status: PromStatusNotOK, Version: "2.25.0"},
+		{Name: "added", Status: PromStatusOK},
+		{Name: "empty", Status: PromStatusOK, NumOfTimeSeries: "10"},
+	}
+	diffs := diffReports(oldResults, newResults)
+
+	var names []string
+	for _, d := range diffs {
+		names = append(names, d.Name+":"+d.Change)
+	}
+	expected := "added:added,down:changed,empty:changed,removed:removed,same:unchanged,upgraded:changed"
+	if got := strings.Join(names, ","); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	down := diffs[1]
+	if down.Status == nil || down.Status.String() != "OK -> NotOK" {
+		t.Errorf("unexpected status change %v", down.Status)
+	}
+	// The series of a NotOK instance are not a change.
+	if down.NumOfTimeSeries != nil || down.Version != nil {
+		t.Errorf("unexpected changes %+v", down)
+	}
+	// There is no percentage from 0.
+	if empty := diffs[2]; empty.NumOfTimeSeries == nil || empty.NumOfTimeSeries.Percent != nil {
+		t.Errorf("unexpected change %+v", empty.NumOfTimeSeries)
+	}
+	upgraded := diffs[5]
+	if upgraded.Version.String() != "2.24.0 -> 2.25.0" {
+		t.Errorf("unexpected version change %v", upgraded.Version)
+	}
+	if got := changeString(upgraded.NumOfTimeSeries); got != "1000 -> 1500 (+500, +50.0%)" {
+		t.Errorf("unexpected series change %q", got)
+	}
+}
+
+func TestNumberChangeString(t *testing.T) {
+	for _, tc := range []struct {
+		old, new string
+		expected string
+	}{
+		{"1000", "1500", "1000 -> 1500 (+500, +50.0%)"},
+		{"1500", "1000", "1500 -> 1000 (-500, -33.3%)"},
+		{"0", "2.5", "0 -> 2.5 (+2.5)"},
+		{"1000", "1000", ""},
+		{"", "1000", ""},
+		{"1000", "n/a", ""},
+	} {
+		if got := changeString(diffNumber(tc.old, tc.new)); got != tc.expected {
+			t.Errorf("%s -> %s: expected %q, got %q", tc.old, tc.new, tc.expected, got)
+		}
+	}
+}
+
+func TestReadReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prom-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		names   string
+		err     bool
+	}{
+		{"json", `[{"name":"p1","status":0},{"name":"p2","status":1}]`, "p1,p2", false},
+		{"json with totals", `{"results":[{"name":"p1"}],"totals":{"instances":1}}`, "p1", false},
+		{"ndjson", "{\"name\":\"p1\"}\n{\"name\":\"p2\"}\n", "p1,p2", false},
+		{"empty", "", "", false},
+		{"csv", "name,status\np1,OK\n", "", true},
+	} {
+		path := filepath.Join(dir, tc.name)
+		if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results, err := readReport(path)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+			continue
+		}
+		var names []string
+		for _, ps := range results {
+			names = append(names, ps.Name)
+		}
+		if got := strings.Join(names, ","); got != tc.names {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.names, got)
+		}
+	}
+	if _, err := readReport(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing report")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	diffs := []*InstanceDiff{
+		{Name: "p1", Change: ChangeUnchanged},
+		{Name: "p2", Change: ChangeChanged, Version: &StringChange{Old: "2.24.0", New: "2.25.0"}},
+	}
+	for _, tc := range []struct {
+		changedOnly bool
+		names       string
+	}{
+		{false, "p1,p2"},
+		{true, "p2"},
+	} {
+		var b bytes.Buffer
+		if err := writeDiff(&b, "json", diffs, tc.changedOnly); err != nil {
+			t.Fatal(err)
+		}
+		var decoded []InstanceDiff
+		if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, d := range decoded {
+			names = append(names, d.Name)
+		}
+		if got := strings.Join(names, ","); got != tc.names {
+			t.Errorf("changedOnly %t: expected %s, got %s", tc.changedOnly, tc.names, got)
+		}
+	}
+
+	// Without any change, the JSON diff is an empty list and not null.
+	var b bytes.Buffer
+	if err := writeDiff(&b, "json", diffs[:1], true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(b.String()) != "[]" {
+		t.Errorf("expected an empty list, got %s", b.String())
+	}
+	if err := writeDiff(&b, "markdown", diffs, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "2.24.0 -> 2.25.0") {
+		t.Errorf("expected the version change in the table:\n%s", b.String())
+	}
+	if err := writeDiff(&b, "yaml", diffs, false); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// emailFormats are the output formats which can be sent by email, with
+// the content type of the email body.
+var emailFormats = map[string]string{
+	"html":     "text/html; charset=utf-8",
+	"markdown": "text/plain; charset=utf-8",
+}
+
+// emailSubjectData is the data of the email subject template.
+type emailSubjectData struct {
+	Instances int
+	OK        int
+	NotOK     int
+	// Date is the run date, '2006-01-02'.
+	Date     string
+	Hostname string
+}
+
+// validateEmail checks the email configuration of an output format.
+func validateEmail(cfg *EmailConfig, format string) error {
+	if _, ok := emailFormats[format]; !ok {
+		return errors.Errorf("the %q format cannot be sent by email, use 'html' or 'markdown'", format)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Smarthost); err != nil {
+		return errors.Wrapf(err, "invalid email smarthost %q", cfg.Smarthost)
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return errors.Wrapf(err, "invalid email from %q", cfg.From)
+	}
+	if len(cfg.To) == 0 {
+		return errors.New("no email recipient, to is empty")
+	}
+	for _, to := range cfg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return errors.Wrapf(err, "invalid email recipient %q", to)
+		}
+	}
+	_, err := template.New("subject").Parse(cfg.Subject)
+	return errors.Wrapf(err, "invalid email subject %q", cfg.Subject)
+}
+
+// emailSubject renders the subject template.
+func emailSubject(cfg *EmailConfig, results []*PromSummary, now time.Time) (string, error) {
+	tmpl, err := template.New("subject").Parse(cfg.Subject)
+	if err != nil {
+		return "", err
+	}
+	t := computeTotals(results)
+	var b strings.Builder
+	err = tmpl.Execute(&b, emailSubjectData{
+		Instances: t.NumOfInstances,
+		OK:        t.NumOfOK,
+		NotOK:     t.NumOfNotOK,
+		Date:      now.Format("2006-01-02"),
+		Hostname:  hostname(),
+	})
+	return b.String(), err
+}
+
+// emailMessage builds the MIME message: the report as body, and the CSV
+// report as attachment if it is not nil.
+func emailMessage(cfg *EmailConfig, subject, contentType string, body, csv []byte, now time.Time) ([]byte, error) {
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-Id: <%d.prom-summary@%s>\r\n", now.UnixNano(), hostname())
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qw := quotedprintable.NewWriter(part)
+	if _, err := qw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := qw.Close(); err != nil {
+		return nil, err
+	}
+
+	if csv != nil {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/csv; charset=utf-8; name=prom-summary.csv"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {"attachment; filename=prom-summary.csv"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(csv)
+		// Lines of base64 encoded content are limited to 76 characters.
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// implicitTLSPort is the SMTP port using implicit TLS, the other ports
+// use STARTTLS.
+var implicitTLSPort = "465"
+
+// sendEmail sends the message through the SMTP server, with STARTTLS or
+// implicit TLS on the port 465, and authenticates if a username is set.
+func sendEmail(cfg *EmailConfig, msg []byte) error {
+	host, port, err := net.SplitHostPort(cfg.Smarthost)
+	if err != nil {
+		return err
+	}
+	tlsCfg := &tls.Config{ServerName: host, InsecureSkipVerify: cfg.TLSInsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.Timeout)}
+	var conn net.Conn
+	if port == implicitTLSPort {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Smarthost, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Smarthost)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Error connecting to %s", cfg.Smarthost)
+	}
+	conn.SetDeadline(time.Now().Add(time.Duration(cfg.Timeout)))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello(hostname()); err != nil {
+		return err
+	}
+	if port != implicitTLSPort {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsCfg); err != nil {
+				return errors.Wrap(err, "Error starting TLS")
+			}
+		} else if cfg.RequireTLS {
+			return errors.Errorf("%s does not support STARTTLS", cfg.Smarthost)
+		}
+	}
+	if cfg.AuthUsername != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.Errorf("%s does not support authentication", cfg.Smarthost)
+		}
+		auth := smtp.PlainAuth(cfg.AuthIdentity, cfg.AuthUsername, cfg.AuthPassword, host)
+		if err := c.Auth(auth); err != nil {
+			return errors.Wrap(err, "Error authenticating")
+		}
+	}
+
+	from, _ := mail.ParseAddress(cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := c.Rcpt(addr.Address); err != nil {
+			return errors.Wrapf(err, "Error adding recipient %s", addr.Address)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
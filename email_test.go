@@ -0,0 +1,355 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// smtpStub is a minimal SMTP server accepting a single session, it
+// records the envelope and the message it receives.
+type smtpStub struct {
+	ln          net.Listener
+	tlsCfg      *tls.Config
+	startTLS    bool
+	implicitTLS bool
+
+	done     chan struct{}
+	tls      bool
+	auth     string
+	from     string
+	to       []string
+	data     []byte
+	commands []string
+}
+
+func newSMTPStub(t *testing.T, startTLS, implicitTLS bool) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{
+		ln:          ln,
+		tlsCfg:      &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}},
+		startTLS:    startTLS,
+		implicitTLS: implicitTLS,
+		done:        make(chan struct{}),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStub) addr() string { return s.ln.Addr().String() }
+
+func (s *smtpStub) close() {
+	s.ln.Close()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func (s *smtpStub) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tlsCfg)
+		s.tls = true
+	}
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			tp.PrintfLine("500 empty command")
+			continue
+		}
+		cmd := strings.ToUpper(fields[0])
+		s.commands = append(s.commands, cmd)
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-stub")
+			if s.startTLS && !s.tls {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			conn = tls.Server(conn, s.tlsCfg)
+			tp = textproto.NewConn(conn)
+			s.tls = true
+		case "AUTH":
+			resp, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			parts := strings.Split(string(resp), "\x00")
+			if len(parts) == 3 {
+				s.auth = parts[1] + ":" + parts[2]
+			}
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(line[4:], " FROM:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(line[4:], " TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			s.data, _ = tp.ReadDotBytes()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unsupported")
+		}
+	}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func testEmailResults() []*PromSummary {
+	return []*PromSummary{
+		{Name: "p1", Address: "http://p1:9090", Status: PromStatusOK, NumOfTimeSeries: "1000"},
+		{Name: "p2", Address: "http://p2:9090", Status: PromStatusNotOK, Error: "connection refused"},
+		{Name: "p3", Address: "http://p3:9090", Status: PromStatusNotOK, Error: "timeout"},
+	}
+}
+
+func testEmailConfig(smarthost string) *EmailConfig {
+	cfg := DefaultEmailConfig
+	cfg.Smarthost = smarthost
+	cfg.From = "Prom Summary <prom-summary@example.com>"
+	cfg.To = []string{"sre@example.com", "Oncall <oncall@example.com>"}
+	cfg.Subject = "[prom-summary] {{ .NotOK }}/{{ .Instances }} NotOK"
+	cfg.TLSInsecureSkipVerify = true
+	cfg.Timeout = model.Duration(5 * time.Second)
+	return &cfg
+}
+
+func TestEmailOutput(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		format      string
+		startTLS    bool
+		implicitTLS bool
+		auth        bool
+		contentType string
+		body        string
+	}{
+		{"html with starttls", "html", true, false, true, "text/html; charset=utf-8", "<table>"},
+		{"markdown with starttls", "markdown", true, false, false, "text/plain; charset=utf-8", "| name"},
+		{"html with implicit tls", "html", false, true, true, "text/html; charset=utf-8", "<table>"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newSMTPStub(t, tc.startTLS, tc.implicitTLS)
+			defer stub.close()
+			if tc.implicitTLS {
+				_, port, _ := net.SplitHostPort(stub.addr())
+				defer func(port string) { implicitTLSPort = port }(implicitTLSPort)
+				implicitTLSPort = port
+			}
+			cfg := testEmailConfig(stub.addr())
+			if tc.auth {
+				cfg.AuthUsername, cfg.AuthPassword = "user", "secret"
+			}
+			outCfg := DefaultOutputConfig
+			outCfg.Format, outCfg.Email = tc.format, cfg
+			out, err := newOutput(outCfg, ColorNever)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := out.finish(testEmailResults()); err != nil {
+				t.Fatal(err)
+			}
+			stub.close()
+
+			if !stub.tls {
+				t.Error("expected the session to use TLS")
+			}
+			if tc.auth && stub.auth != "user:secret" {
+				t.Errorf("expected the user:secret credentials, got %q", stub.auth)
+			}
+			if stub.from != "prom-summary@example.com" {
+				t.Errorf("unexpected sender %q", stub.from)
+			}
+			if strings.Join(stub.to, ",") != "sre@example.com,oncall@example.com" {
+				t.Errorf("unexpected recipients %v", stub.to)
+			}
+			checkEmailMessage(t, stub.data, tc.contentType, tc.body)
+		})
+	}
+}
+
+func checkEmailMessage(t *testing.T, data []byte, contentType, body string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "[prom-summary] 2/3 NotOK" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("unexpected content type %q", msg.Header.Get("Content-Type"))
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := mr.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := part.Header.Get("Content-Type"); got != contentType {
+		t.Errorf("expected the body content type %q, got %q", contentType, got)
+	}
+	if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+		t.Errorf("unexpected body encoding %q", got)
+	}
+	raw, err := ioutil.ReadAll(quotedprintable.NewReader(part))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), body) || !strings.Contains(string(raw), "connection refused") {
+		t.Errorf("expected %q and the errors in the body:\n%s", body, raw)
+	}
+
+	part, err = mr.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := part.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("unexpected attachment encoding %q", got)
+	}
+	if got := part.Header.Get("Content-Disposition"); got != "attachment; filename=prom-summary.csv" {
+		t.Errorf("unexpected attachment disposition %q", got)
+	}
+	encoded, err := ioutil.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SMTP stub reads the lines without their CRLF ending.
+	csv, err := base64.StdEncoding.DecodeString(strings.Replace(string(encoded), "\n", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(encoded), "\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 line longer than 76 characters: %q", line)
+		}
+	}
+	if !strings.HasPrefix(string(csv), "name,address,status,error,") ||
+		!strings.Contains(string(csv), "p2,http://p2:9090,NotOK,connection refused,") {
+		t.Errorf("unexpected CSV attachment:\n%s", csv)
+	}
+	if _, err := mr.NextRawPart(); err == nil {
+		t.Error("unexpected third part")
+	}
+}
+
+func TestSendEmailRequireTLS(t *testing.T) {
+	for _, requireTLS := range []bool{true, false} {
+		stub := newSMTPStub(t, false, false)
+		cfg := testEmailConfig(stub.addr())
+		cfg.RequireTLS = requireTLS
+		err := sendEmail(cfg, []byte("Subject: test\r\n\r\nbody\r\n"))
+		stub.close()
+		if requireTLS {
+			if err == nil {
+				t.Error("expected an error without STARTTLS")
+			}
+			if stub.data != nil {
+				t.Error("unexpected message sent without TLS")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if !strings.Contains(string(stub.data), "body") {
+			t.Errorf("unexpected message %q", stub.data)
+		}
+	}
+}
+
+func TestEmailSubject(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		subject  string
+		expected string
+	}{
+		{"{{ .NotOK }} NotOK", "2 NotOK"},
+		{"{{ .OK }}/{{ .Instances }} OK on {{ .Date }}", "1/3 OK on 2021-03-01"},
+		{"static", "static"},
+	} {
+		got, err := emailSubject(&EmailConfig{Subject: tc.subject}, testEmailResults(), now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.subject, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.subject, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ExporterConfig are the settings of the exporter command.
+type ExporterConfig struct {
+	ListenAddress string
+	MetricsPath   string
+	// Interval is the collection interval. If it is 0, the summaries are
+	// collected on scrape, and cached for CacheTTL.
+	Interval time.Duration
+	CacheTTL time.Duration
+	// Timeout is the timeout of a collection.
+	Timeout time.Duration
+}
+
+var collectionDurationDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "collection_duration_seconds"),
+	"The duration of the last collection of the summaries.", nil, nil)
+
+// exporter is a prometheus.Collector exposing the cached summaries of the
+// configured Prometheus instances.
+type exporter struct {
+	cache *summaryCache
+}
+
+// Describe implements the prometheus.Collector interface.
+func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
+	summaryCollector{}.Describe(ch)
+	ch <- collectionDurationDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	results, _, duration := e.cache.summaries()
+	summaryCollector{results: results}.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(collectionDurationDesc, prometheus.GaugeValue, duration.Seconds())
+}
+
+var probeDurationDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "probe_duration_seconds"),
+	"The duration of the collection of the summary of the probed target.", nil, nil)
+
+// probeCollector is a prometheus.Collector collecting the summary of a
+// single Prometheus instance, on every scrape.
+type probeCollector struct {
+	ctx     context.Context
+	name    string
+	promCfg PrometheusConfig
+}
+
+// Describe implements the prometheus.Collector interface.
+func (p probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	summaryCollector{}.Describe(ch)
+	ch <- probeDurationDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (p probeCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	ps := collect(p.ctx, p.name, p.promCfg, false)
+	summaryCollector{results: []*PromSummary{ps}}.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(probeDurationDesc, prometheus.GaugeValue, time.Since(start).Seconds())
+}
+
+// probeTarget returns the Prometheus instance of the probe target, which is
+// either the name of a configured instance or the URL of a Prometheus.
+func probeTarget(promCfgs map[string]PrometheusConfig, target string) (PrometheusConfig, error) {
+	if promCfg, ok := promCfgs[target]; ok {
+		return promCfg, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return PrometheusConfig{}, errors.Errorf("unknown target %q, it is neither a configured instance nor a URL", target)
+	}
+	return PrometheusConfig{Address: target}, nil
+}
+
+// probeTimeoutOffset is subtracted from the scrape timeout, for the probe
+// to end before the scrape times out.
+const probeTimeoutOffset = 500 * time.Millisecond
+
+// probeTimeout returns the collection timeout of a probe: the scrape timeout
+// of the Prometheus scraping the exporter minus an offset, if it is shorter
+// than the exporter's timeout. Like the blackbox exporter, the exporter's
+// timeout is used if the scrape timeout is invalid or shorter than the offset.
+func probeTimeout(scrapeTimeout string, timeout time.Duration) time.Duration {
+	if scrapeTimeout == "" {
+		return timeout
+	}
+	s, err := strconv.ParseFloat(scrapeTimeout, 64)
+	if err != nil || s <= 0 {
+		return timeout
+	}
+	d := time.Duration(s*float64(time.Second)) - probeTimeoutOffset
+	if d <= 0 || d > timeout {
+		return timeout
+	}
+	return d
+}
+
+// probeHandler collects the summary of the 'target' query parameter, in the
+// blackbox exporter style. The collection timeout is the scrape timeout of
+// the Prometheus scraping the exporter, if it is shorter than the exporter's.
+func probeHandler(promCfgs map[string]PrometheusConfig, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		promCfg, err := probeTarget(promCfgs, target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(),
+			probeTimeout(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), timeout))
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(probeCollector{ctx: ctx, name: target, promCfg: promCfg})
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// writeLandingPage writes the exporter landing page, with a probe link
+// for every configured instance.
+func writeLandingPage(w io.Writer, metricsPath string, promCfgs map[string]PrometheusConfig) {
+	names := make([]string, 0, len(promCfgs))
+	for name := range promCfgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, `<html>
+<head><title>Prom-summary exporter</title></head>
+<body>
+<h1>Prom-summary exporter</h1>
+<p><a href="%s">Metrics</a></p>
+`, html.EscapeString(metricsPath))
+	for _, name := range names {
+		fmt.Fprintf(w, "<p><a href=\"/probe?target=%s\">Probe %s</a></p>\n",
+			html.EscapeString(url.QueryEscape(name)), html.EscapeString(name))
+	}
+	fmt.Fprint(w, `</body>
+</html>
+`)
+}
+
+// runExporter serves the summary metrics of the Prometheus instances.
+func runExporter(promCfgs map[string]PrometheusConfig, cfg ExporterConfig) error {
+	if cfg.Interval < 0 {
+		return errors.Errorf("invalid collection interval %s, it must not be negative", cfg.Interval)
+	}
+	if cfg.Timeout <= 0 {
+		return errors.Errorf("invalid collection timeout %s, it must be positive", cfg.Timeout)
+	}
+	cache := newSummaryCache(promCfgs, cfg.Interval, cfg.CacheTTL, cfg.Timeout, false)
+	if cfg.Interval > 0 {
+		go cache.run(context.Background())
+	}
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(&exporter{cache: cache}); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle("/probe", probeHandler(promCfgs, cfg.Timeout))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		writeLandingPage(w, cfg.MetricsPath, promCfgs)
+	})
+
+	fmt.Fprintln(os.Stderr, "Listening on", cfg.ListenAddress)
+	return http.ListenAndServe(cfg.ListenAddress, mux)
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		scrapeTimeout string
+		timeout       time.Duration
+		expected      time.Duration
+	}{
+		{"", time.Minute, time.Minute},
+		{"10", time.Minute, 9500 * time.Millisecond},
+		{"2.5", time.Minute, 2 * time.Second},
+		{"120", time.Minute, time.Minute},
+		{"0.000001", time.Minute, time.Minute},
+		{"0.5", time.Minute, time.Minute},
+		{"0", time.Minute, time.Minute},
+		{"-1", time.Minute, time.Minute},
+		{"abc", time.Minute, time.Minute},
+	} {
+		if got := probeTimeout(tc.scrapeTimeout, tc.timeout); got != tc.expected {
+			t.Errorf("probeTimeout(%q, %s) = %s, expected %s", tc.scrapeTimeout, tc.timeout, got, tc.expected)
+		}
+	}
+}
+
+func TestWriteLandingPage(t *testing.T) {
+	var b strings.Builder
+	writeLandingPage(&b, "/metrics", map[string]PrometheusConfig{
+		"prom-b":    {Address: "http://b:9090"},
+		"prom a&co": {Address: "http://a:9090"},
+	})
+	page := b.String()
+	for _, expected := range []string{
+		`<a href="/metrics">Metrics</a>`,
+		`<a href="/probe?target=prom+a%26co">Probe prom a&amp;co</a>`,
+		`<a href="/probe?target=prom-b">Probe prom-b</a>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected %q in the landing page:\n%s", expected, page)
+		}
+	}
+	if strings.Contains(page, "prometheus_1") {
+		t.Errorf("unexpected hard-coded instance in the landing page:\n%s", page)
+	}
+	if strings.Index(page, "prom a&amp;co") > strings.Index(page, "prom-b") {
+		t.Errorf("expected the probe links sorted by name:\n%s", page)
+	}
+}
+
+func TestRunExporterInvalidSettings(t *testing.T) {
+	for _, tc := range []struct {
+		interval time.Duration
+		timeout  time.Duration
+	}{
+		{-time.Second, time.Minute},
+		{0, 0},
+		{time.Minute, -time.Second},
+	} {
+		err := runExporter(nil, ExporterConfig{
+			ListenAddress: "127.0.0.1:0",
+			MetricsPath:   "/metrics",
+			Interval:      tc.interval,
+			CacheTTL:      time.Minute,
+			Timeout:       tc.timeout,
+		})
+		if err == nil {
+			t.Errorf("interval %s, timeout %s: expected an error", tc.interval, tc.timeout)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileNameData are the template placeholders of the output file path.
+type fileNameData struct {
+	// Date is the run date, '2006-01-02'.
+	Date string
+	// Time is the run time, '150405'.
+	Time string
+	// Timestamp is the run Unix timestamp.
+	Timestamp string
+	// Format is the output format.
+	Format string
+	// Hostname is the host name of the machine running prom-summary.
+	Hostname string
+}
+
+// renderFileName expands the placeholders of the output file path:
+// the template fields of fileNameData, for example '{{ .Date }}', and
+// the strftime directives, for example '%Y-%m-%d'.
+func renderFileName(path, format string, now time.Time) (string, error) {
+	data := fileNameData{
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("150405"),
+		Timestamp: strconv.FormatInt(now.Unix(), 10),
+		Format:    format,
+		Hostname:  hostname(),
+	}
+	s, err := executeFileName(path, data)
+	if err != nil {
+		return "", err
+	}
+	return strftime(s, now, false), nil
+}
+
+// placeholderMarker delimits the placeholders in the path rendered by
+// newFileNamePattern, a path cannot contain it.
+const placeholderMarker = "\x00"
+
+// placeholderPatterns are the regular expressions of the rendered
+// placeholders, by strftime directive, 't' is the '{{ .Time }}' placeholder.
+var placeholderPatterns = map[byte]string{
+	'Y': `\d{4}`, 'y': `\d{2}`, 'm': `\d{2}`, 'd': `\d{2}`, 'H': `\d{2}`, 'M': `\d{2}`, 'S': `\d{2}`,
+	'j': `\d{3}`, 'F': `\d{4}-\d{2}-\d{2}`, 'T': `\d{2}:\d{2}:\d{2}`, 's': `\d+`, 't': `\d{6}`,
+}
+
+func placeholder(directive byte) string {
+	return placeholderMarker + string(directive) + placeholderMarker
+}
+
+// fileNamePattern matches the files rendered from an output file path,
+// whatever the time they have been written at.
+type fileNamePattern struct {
+	// glob lists the candidate files, re only matches the rendered layout.
+	glob string
+	re   *regexp.Regexp
+}
+
+// newFileNamePattern returns the pattern of the output file path. The file
+// name must have a fixed part around its placeholders, not to match the
+// unrelated files of the directory.
+func newFileNamePattern(path, format string) (*fileNamePattern, error) {
+	data := fileNameData{
+		Date:      placeholder('F'),
+		Time:      placeholder('t'),
+		Timestamp: placeholder('s'),
+		Format:    format,
+		Hostname:  hostname(),
+	}
+	s, err := executeFileName(path, data)
+	if err != nil {
+		return nil, err
+	}
+	s = filepath.Clean(strftime(s, time.Time{}, true))
+
+	base := strings.Split(s[strings.LastIndex(s, string(filepath.Separator))+1:], placeholderMarker)
+	fixed := ""
+	for i := 0; i < len(base); i += 2 {
+		fixed += base[i]
+	}
+	if len(base) > 1 && fixed == "" {
+		return nil, errors.Errorf("the file name %q has no fixed part around its placeholders", path)
+	}
+
+	var glob, re strings.Builder
+	re.WriteString("^")
+	// The placeholders are at the odd indexes.
+	for i, part := range strings.Split(s, placeholderMarker) {
+		if i%2 == 1 {
+			glob.WriteString("*")
+			re.WriteString(placeholderPatterns[part[0]])
+			continue
+		}
+		glob.WriteString(escapeGlob(part))
+		re.WriteString(regexp.QuoteMeta(part))
+	}
+	re.WriteString("$")
+	return &fileNamePattern{glob: glob.String(), re: regexp.MustCompile(re.String())}, nil
+}
+
+// matches returns the files matching the pattern.
+func (p *fileNamePattern) matches() ([]string, error) {
+	candidates, err := filepath.Glob(p.glob)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, c := range candidates {
+		if p.re.MatchString(c) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+// escapeGlob escapes the glob metacharacters, the backslash is the path
+// separator on Windows, it cannot escape them there.
+func escapeGlob(s string) string {
+	if filepath.Separator == '\\' {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func executeFileName(path string, data fileNameData) (string, error) {
+	tmpl, err := template.New("file").Option("missingkey=error").Parse(path)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return name
+}
+
+// strftime expands the strftime directives of s. If mark is true, every
+// directive is replaced by its placeholder instead.
+func strftime(s string, t time.Time, mark bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		var v string
+		switch s[i] {
+		case 'Y':
+			v = fmt.Sprintf("%04d", t.Year())
+		case 'y':
+			v = fmt.Sprintf("%02d", t.Year()%100)
+		case 'm':
+			v = fmt.Sprintf("%02d", int(t.Month()))
+		case 'd':
+			v = fmt.Sprintf("%02d", t.Day())
+		case 'H':
+			v = fmt.Sprintf("%02d", t.Hour())
+		case 'M':
+			v = fmt.Sprintf("%02d", t.Minute())
+		case 'S':
+			v = fmt.Sprintf("%02d", t.Second())
+		case 'j':
+			v = fmt.Sprintf("%03d", t.YearDay())
+		case 'F':
+			v = t.Format("2006-01-02")
+		case 'T':
+			v = t.Format("15:04:05")
+		case 's':
+			v = strconv.FormatInt(t.Unix(), 10)
+		case '%':
+			b.WriteByte('%')
+			continue
+		default:
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+			continue
+		}
+		if mark {
+			v = placeholder(s[i])
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// pruneFiles removes the old reports matching the pattern: only the
+// keepLast most recent ones are kept, and the ones older than maxAge are
+// removed. A zero keepLast or maxAge disables the policy. The current report
+// is never removed.
+func pruneFiles(pattern *fileNamePattern, current string, keepLast int, maxAge time.Duration) error {
+	if keepLast <= 0 && maxAge <= 0 {
+		return nil
+	}
+	matches, err := pattern.matches()
+	if err != nil {
+		return err
+	}
+	type report struct {
+		path    string
+		modTime time.Time
+	}
+	var reports []report
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil || fi.IsDir() || m == filepath.Clean(current) {
+			continue
+		}
+		reports = append(reports, report{path: m, modTime: fi.ModTime()})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].modTime.After(reports[j].modTime)
+	})
+
+	now := time.Now()
+	for i, r := range reports {
+		// The current report counts as the most recent one.
+		tooMany := keepLast > 0 && i+1 >= keepLast
+		tooOld := maxAge > 0 && now.Sub(r.modTime) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(r.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
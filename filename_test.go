@@ -0,0 +1,145 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFileNamePattern(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		match    []string
+		notMatch []string
+	}{
+		{
+			path:     "/dir/{{ .Date }}.csv",
+			match:    []string{"/dir/2021-03-01.csv"},
+			notMatch: []string{"/dir/keep-me.csv", "/dir/other.csv", "/dir/2021-03-01.json", "/dir/2021-03-01-x.csv"},
+		},
+		{
+			path:     "/dir/report-%Y%m%d-%H%M%S.{{ .Format }}",
+			match:    []string{"/dir/report-20210301-120000.csv"},
+			notMatch: []string{"/dir/report-important.csv", "/dir/report-2021.csv"},
+		},
+		{
+			path:     "/dir/%Y/report-{{ .Time }}.csv",
+			match:    []string{"/dir/2021/report-120000.csv"},
+			notMatch: []string{"/dir/2021/report-12.csv", "/dir/old/report-120000.csv"},
+		},
+		{
+			path:     "/dir/report-{{ .Timestamp }}.csv",
+			match:    []string{"/dir/report-1614600000.csv"},
+			notMatch: []string{"/dir/report-.csv", "/dir/report-abc.csv"},
+		},
+		{
+			path:     "/dir/[a]*?-%F.csv",
+			match:    []string{"/dir/[a]*?-2021-03-01.csv"},
+			notMatch: []string{"/dir/a-2021-03-01.csv", "/dir/[a]xy-2021-03-01.csv"},
+		},
+	} {
+		pattern, err := newFileNamePattern(tc.path, "csv")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.path, err)
+			continue
+		}
+		for _, m := range tc.match {
+			if !pattern.re.MatchString(m) {
+				t.Errorf("%s: expected %s to match", tc.path, m)
+			}
+		}
+		for _, m := range tc.notMatch {
+			if pattern.re.MatchString(m) {
+				t.Errorf("%s: expected %s not to match", tc.path, m)
+			}
+		}
+	}
+}
+
+func TestFileNamePatternWithoutFixedPart(t *testing.T) {
+	for _, path := range []string{"/dir/{{ .Date }}", "/dir/%Y%m%d", "/dir/{{ .Timestamp }}%H"} {
+		if _, err := newFileNamePattern(path, "csv"); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+	for _, path := range []string{"/dir/report.csv", "/%Y/report.csv", "/dir/{{ .Date }}.csv"} {
+		if _, err := newFileNamePattern(path, "csv"); err != nil {
+			t.Errorf("%s: unexpected error: %s", path, err)
+		}
+	}
+}
+
+func TestPruneFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prom-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"2021-03-03.csv", 0},
+		{"2021-03-02.csv", time.Hour},
+		{"2021-03-01.csv", 2 * time.Hour},
+		{"keep-me.csv", 3 * time.Hour},
+		{"other.csv", 4 * time.Hour},
+		{"important.csv", 5 * time.Hour},
+		{"*.csv", 6 * time.Hour},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pattern, err := newFileNamePattern(filepath.Join(dir, "{{ .Date }}.csv"), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneFiles(pattern, filepath.Join(dir, "2021-03-03.csv"), 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, fi := range infos {
+		remaining = append(remaining, fi.Name())
+	}
+	sort.Strings(remaining)
+	expected := []string{"*.csv", "2021-03-02.csv", "2021-03-03.csv", "important.csv", "keep-me.csv", "other.csv"}
+	if len(remaining) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, remaining)
+	}
+	for i := range expected {
+		if remaining[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, remaining)
+		}
+	}
+}
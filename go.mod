@@ -3,11 +3,15 @@ module github.com/ntk148v/prom-summary
 go 1.14
 
 require (
-	github.com/alecthomas/units v0.0.0-20210208195552-ff826a37aa15 // indirect
+	github.com/alecthomas/units v0.0.0-20210208195552-ff826a37aa15
+	github.com/golang/snappy v0.0.2
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/client_golang v1.9.0
 	github.com/prometheus/common v0.15.0
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	google.golang.org/protobuf v1.23.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	gopkg.in/yaml.v2 v2.3.0
 )
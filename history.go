@@ -0,0 +1,167 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyOpenTimeout is how long to wait for the history database lock,
+// it is held by any other running prom-summary.
+const historyOpenTimeout = 10 * time.Second
+
+// historyBucket is the root bucket of the history database. It contains
+// one bucket per Prometheus instance, whose keys are the collection times,
+// in Unix nanoseconds, and values the JSON encoded summaries.
+var historyBucket = []byte("summaries")
+
+// historyStore is the local history of the collected summaries.
+type historyStore struct {
+	db *bolt.DB
+}
+
+// openHistory opens the history database, it is created if it does not exist.
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: historyOpenTimeout})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error opening history database %s", path)
+	}
+	return &historyStore{db: db}, nil
+}
+
+// Close closes the history database.
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// Append stores the summaries, keyed by instance and collection time.
+func (h *historyStore) Append(results []*PromSummary) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		for _, ps := range results {
+			b, err := root.CreateBucketIfNotExists([]byte(ps.Name))
+			if err != nil {
+				return err
+			}
+			content, err := json.Marshal(ps)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(historyKey(ps.CollectedAt), content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns the summaries collected in [since, until), sorted by instance
+// name then collection time. All the instances are returned if names is empty.
+func (h *historyStore) Query(names []string, since, until time.Time) ([]*PromSummary, error) {
+	var results []*PromSummary
+	err := h.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(historyBucket)
+		if root == nil {
+			return nil
+		}
+		if len(names) == 0 {
+			if err := root.ForEach(func(k, v []byte) error {
+				if v == nil {
+					names = append(names, string(k))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		sort.Strings(names)
+
+		min, max := historyKey(since), historyKey(until)
+		for _, name := range names {
+			b := root.Bucket([]byte(name))
+			if b == nil {
+				continue
+			}
+			c := b.Cursor()
+			for k, v := c.Seek(min); k != nil && bytes.Compare(k, max) < 0; k, v = c.Next() {
+				ps := &PromSummary{}
+				if err := json.Unmarshal(v, ps); err != nil {
+					return errors.Wrapf(err, "Error decoding history of %s", name)
+				}
+				results = append(results, ps)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// Last returns the most recent summary of each instance collected before
+// the given time, by instance name.
+func (h *historyStore) Last(before time.Time) (map[string]*PromSummary, error) {
+	last := make(map[string]*PromSummary)
+	err := h.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(historyBucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(name, _ []byte) error {
+			b := root.Bucket(name)
+			if b == nil {
+				return nil
+			}
+			c := b.Cursor()
+			k, v := c.Seek(historyKey(before))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+			if k == nil {
+				return nil
+			}
+			ps := &PromSummary{}
+			if err := json.Unmarshal(v, ps); err != nil {
+				return errors.Wrapf(err, "Error decoding history of %s", name)
+			}
+			last[string(name)] = ps
+			return nil
+		})
+	})
+	return last, err
+}
+
+// historyKey is the big endian Unix nanoseconds of t, so that the keys
+// are sorted by time.
+func historyKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlReport is the data of the HTML report template.
+type htmlReport struct {
+	Headers []string
+	Rows    []htmlRow
+	Totals  []string
+}
+
+type htmlRow struct {
+	OK    bool
+	Cells []string
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Prometheus Summary</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; font-size: 14px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th { background: #eee; }
+tr.notok td { background: #ffc7ce; }
+tfoot td { font-weight: bold; }
+</style>
+</head>
+<body>
+<table>
+<thead>
+<tr>{{ range .Headers }}<th>{{ . }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr{{ if not .OK }} class="notok"{{ end }}>{{ range .Cells }}<td>{{ . }}</td>{{ end }}</tr>
+{{- end }}
+</tbody>
+{{- if .Totals }}
+<tfoot>
+<tr>{{ range .Totals }}<td>{{ . }}</td>{{ end }}</tr>
+</tfoot>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// writeHTML writes the results as a HTML table, NotOK instances are highlighted.
+// The totals row is written if totals is not nil.
+func writeHTML(w io.Writer, columns []Column, results []*PromSummary, totals *Totals, humanize bool) error {
+	report := htmlReport{Headers: headers(columns)}
+	for _, record := range results {
+		report.Rows = append(report.Rows, htmlRow{
+			OK:    record.Status == PromStatusOK,
+			Cells: row(columns, record, humanize),
+		})
+	}
+	if totals != nil {
+		report.Totals = totalsRow(columns, totals, humanize)
+	}
+	return htmlTemplate.Execute(w, report)
+}
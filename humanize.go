@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/prometheus/common/model"
+)
+
+// humanFormats are the formats meant to be read by humans, the humanize
+// option only applies to them, the other formats always keep raw values.
+var humanFormats = map[string]bool{
+	"table":          true,
+	"table-compact":  true,
+	"table-vertical": true,
+	"markdown":       true,
+	"html":           true,
+}
+
+var siPrefixes = []string{"", "k", "M", "G", "T", "P", "E"}
+
+// humanizeNumber renders a number with a SI suffix, for example 2.4M.
+// Values which are not numbers are returned as is.
+func humanizeNumber(s string) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	// The unit is chosen after rounding, 999999 is 1M and not 1000k.
+	i := 0
+	for math.Abs(roundDecimals(f, 1)) >= 1000 && i < len(siPrefixes)-1 {
+		f /= 1000
+		i++
+	}
+	return trimDecimals(f, 1) + siPrefixes[i]
+}
+
+var durationUnits = []struct {
+	name string
+	d    time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+	{"second", time.Second},
+}
+
+// humanizeDuration renders a Prometheus duration in words,
+// for example '1y2w' becomes '1 year 14 days'.
+func humanizeDuration(d time.Duration) string {
+	if d <= 0 {
+		return "0 seconds"
+	}
+	var parts []string
+	for _, u := range durationUnits {
+		n := d / u.d
+		if n == 0 {
+			continue
+		}
+		d -= n * u.d
+		name := u.name
+		if n > 1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, name))
+	}
+	return strings.Join(parts, " ")
+}
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanizeBytes renders a size in bytes with a binary unit, for example '10 GiB'.
+func humanizeBytes(b int64) string {
+	f, i := float64(b), 0
+	for roundDecimals(f, 1) >= 1024 && i < len(byteUnits)-1 {
+		f /= 1024
+		i++
+	}
+	return trimDecimals(f, 1) + " " + byteUnits[i]
+}
+
+// humanizeRetention renders a storage retention, which is either a
+// duration, a size or both, for example '15d or 10GiB' becomes
+// '15 days or 10 GiB'. The parts which cannot be parsed are kept as is.
+func humanizeRetention(s string) string {
+	parts := strings.Split(s, " or ")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if d, err := model.ParseDuration(part); err == nil {
+			parts[i] = humanizeDuration(time.Duration(d))
+			continue
+		}
+		if b, err := units.ParseStrictBytes(part); err == nil {
+			parts[i] = humanizeBytes(b)
+		}
+	}
+	return strings.Join(parts, " or ")
+}
+
+// roundDecimals rounds f to prec decimals.
+func roundDecimals(f float64, prec int) float64 {
+	p := math.Pow(10, float64(prec))
+	return math.Round(f*p) / p
+}
+
+// trimDecimals formats f with at most prec decimals, without trailing zeros.
+func trimDecimals(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanizeNumber(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected string
+	}{
+		{"0", "0"},
+		{"999", "999"},
+		{"999.96", "1k"},
+		{"1000", "1k"},
+		{"1500", "1.5k"},
+		{"999949", "999.9k"},
+		{"999950", "1M"},
+		{"999999", "1M"},
+		{"2387664", "2.4M"},
+		{"-999999", "-1M"},
+		{"1.5342E+04", "15.3k"},
+		{"1e21", "1000E"},
+		{"", ""},
+		{"n/a", "n/a"},
+	} {
+		if got := humanizeNumber(tc.in); got != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	for _, tc := range []struct {
+		in       int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1 KiB"},
+		{1536, "1.5 KiB"},
+		{1048575, "1 MiB"},
+		{10 << 30, "10 GiB"},
+	} {
+		if got := humanizeBytes(tc.in); got != tc.expected {
+			t.Errorf("%d: expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	for _, tc := range []struct {
+		in       time.Duration
+		expected string
+	}{
+		{0, "0 seconds"},
+		{time.Second, "1 second"},
+		{90 * time.Minute, "1 hour 30 minutes"},
+		{15 * 24 * time.Hour, "15 days"},
+		{(365 + 14) * 24 * time.Hour, "1 year 14 days"},
+	} {
+		if got := humanizeDuration(tc.in); got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestHumanizeRetention(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected string
+	}{
+		{"15d", "15 days"},
+		{"10GiB", "10 GiB"},
+		{"90d or 10GiB", "90 days or 10 GiB"},
+		{"unknown", "unknown"},
+	} {
+		if got := humanizeRetention(tc.in); got != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.in, tc.expected, got)
+		}
+	}
+}
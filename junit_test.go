@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	columns, err := parseColumns([]string{"name", "status", "number_of_time_series"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []*PromSummary{
+		{Name: "p1", Address: "http://p1:9090", Status: PromStatusOK, NumOfTimeSeries: "1000"},
+		{Name: "p2", Address: "http://p2:9090", Status: PromStatusNotOK, Error: "connection refused"},
+		{Name: "p3", Address: "http://p3:9090", Status: PromStatusNotOK, Error: "<timeout> & \"retry\""},
+	}
+	for _, tc := range []struct {
+		name     string
+		results  []*PromSummary
+		tests    int
+		failures int
+	}{
+		{"no instance", nil, 0, 0},
+		{"all OK", results[:1], 1, 0},
+		{"with NotOK", results, 3, 2},
+	} {
+		var b bytes.Buffer
+		if err := writeJUnit(&b, columns, tc.results); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if !strings.HasPrefix(b.String(), xml.Header) {
+			t.Errorf("%s: no XML header", tc.name)
+		}
+		var suites JUnitTestSuites
+		if err := xml.Unmarshal(b.Bytes(), &suites); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if suites.Tests != tc.tests || suites.Failures != tc.failures {
+			t.Errorf("%s: expected %d tests and %d failures, got %d and %d",
+				tc.name, tc.tests, tc.failures, suites.Tests, suites.Failures)
+		}
+		if len(suites.Suites) != 1 || len(suites.Suites[0].TestCases) != tc.tests {
+			t.Fatalf("%s: expected a single suite of %d test cases, got %+v", tc.name, tc.tests, suites.Suites)
+		}
+		suite := suites.Suites[0]
+		if suite.Tests != tc.tests || suite.Failures != tc.failures {
+			t.Errorf("%s: unexpected suite counts %d and %d", tc.name, suite.Tests, suite.Failures)
+		}
+		for i, ps := range tc.results {
+			c := suite.TestCases[i]
+			if c.Name != ps.Name || c.ClassName != "prom-summary."+ps.Name || c.SystemOut != ps.Address {
+				t.Errorf("%s: unexpected test case %+v", tc.name, c)
+			}
+			if len(c.Properties) != len(columns) {
+				t.Errorf("%s: expected %d properties, got %+v", tc.name, len(columns), c.Properties)
+			} else if c.Properties[1].Name != "status" || c.Properties[1].Value != ps.Status.String() {
+				t.Errorf("%s: unexpected status property %+v", tc.name, c.Properties[1])
+			}
+			if (ps.Status != PromStatusOK) != (c.Failure != nil) {
+				t.Errorf("%s: unexpected failure %+v for status %s", tc.name, c.Failure, ps.Status)
+				continue
+			}
+			if c.Failure != nil && (c.Failure.Message != ps.Error || c.Failure.Type != "NotOK") {
+				t.Errorf("%s: unexpected failure %+v", tc.name, c.Failure)
+			}
+		}
+	}
+}
@@ -16,205 +16,376 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
-	promclient "github.com/prometheus/client_golang/api"
-	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"gopkg.in/alecthomas/kingpin.v2"
-	"gopkg.in/yaml.v2"
 )
 
-var headers = []string{
-	"name", "address", "status", "error", "version",
-	"storage retention", "number of active targets",
-	"number of dropped targets", "number of time series",
-	"number of chunks", "number of ingested samples per seconds",
-}
-
-func initClient(address, username, password string) (prometheus.API, error) {
-	promCfg := promclient.Config{Address: address}
-	if username != "" && password != "" {
-		promCfg.RoundTripper = &BasicAuthTransport{
-			Username: username,
-			Password: password,
-		}
-	}
-	client, err := promclient.NewClient(promCfg)
-	if err != nil {
-		return nil, err
-	}
-	api := prometheus.NewAPI(client)
-	return api, nil
-}
-
 func main() {
 
 	a := kingpin.New(filepath.Base(os.Args[0]), "A lazy tool written by Golang to export Prometheus summary.")
 	var (
 		cfgFile string
+		columns string
+		sortBy  string
+		color   string
+		watch   time.Duration
 		cfg     *Config
-		results []*PromSummary
-		wg      sync.WaitGroup
 	)
 	a.Flag("config.file", "Prom-summary configuration file path.").
 		Default("etc/config.yml").StringVar(&cfgFile)
+	a.Flag("columns", "Comma-separated list of columns to output, overrides the columns of every output.").
+		StringVar(&columns)
+	a.Flag("sort", "Column to sort the results by, '<column>[:asc|:desc]', overrides the sort_by of every output.").
+		StringVar(&sortBy)
+	a.Flag("color", "Colourise the table on stdout: 'auto' only for a terminal, 'always' or 'never'.").
+		Default(ColorAuto).EnumVar(&color, ColorAuto, ColorAlways, ColorNever)
+	a.Flag("watch", "Collect the summaries every interval and redraw the table, like watch. "+
+		"Uses the columns, sort and thresholds of the first output.").
+		DurationVar(&watch)
+
+	a.Command("summary", "Export the summary of the Prometheus instances (default).").Default()
+	diffCmd := a.Command("diff", "Compare two JSON reports produced by prom-summary.")
+	diffOld := diffCmd.Arg("old", "The old JSON report.").Required().ExistingFile()
+	diffNew := diffCmd.Arg("new", "The new JSON report.").Required().ExistingFile()
+	diffFormat := diffCmd.Flag("format", "Diff output format: 'table', 'markdown' or 'json'.").
+		Default("table").Enum(diffFormats...)
+	diffChangedOnly := diffCmd.Flag("changed-only", "Only show the instances which have changed.").Bool()
+	historyCmd := a.Command("history", "Query the history of the collected summaries.")
+	historyInstances := historyCmd.Flag("instance", "Instance to query, can be repeated. All of them by default.").
+		Strings()
+	historySince := historyCmd.Flag("since", "Query the summaries collected since this duration ago.").
+		Default("7d").String()
+	historyFormat := historyCmd.Flag("format", "History output format, one of the output formats.").
+		Default("table").Enum(formats...)
+	historyPath := historyCmd.Flag("history.path", "History database file path, overrides history_config.path.").
+		String()
+
+	exporterCmd := a.Command("exporter", "Serve the summary metrics of the Prometheus instances, "+
+		"and of a single one on /probe?target=<name or URL>.")
+	var exporterCfg ExporterConfig
+	exporterCmd.Flag("web.listen-address", "Address to listen on for the metrics.").
+		Default(":9799").StringVar(&exporterCfg.ListenAddress)
+	exporterCmd.Flag("web.telemetry-path", "Path under which to expose the metrics.").
+		Default("/metrics").StringVar(&exporterCfg.MetricsPath)
+	exporterCmd.Flag("collection.interval", "Interval between the collections, 0 collects on scrape.").
+		Default("0s").DurationVar(&exporterCfg.Interval)
+	exporterCmd.Flag("collection.cache-ttl", "How long the summaries collected on scrape are cached.").
+		Default("30s").DurationVar(&exporterCfg.CacheTTL)
+	exporterCmd.Flag("collection.timeout", "Timeout of a collection.").
+		Default("1m").DurationVar(&exporterCfg.Timeout)
+
+	serveCmd := a.Command("serve", "Refresh the summaries on a schedule and serve them with a REST API.")
+	var serverCfg ServerConfig
+	serveCmd.Flag("web.listen-address", "Address to listen on for the API.").
+		Default(":9799").StringVar(&serverCfg.ListenAddress)
+	serveCmd.Flag("refresh.interval", "Interval between the refreshes of the summaries.").
+		Default("1m").DurationVar(&serverCfg.Interval)
+	serveCmd.Flag("collection.timeout", "Timeout of a collection.").
+		Default("1m").DurationVar(&serverCfg.Timeout)
 
-	_, err := a.Parse(os.Args[1:])
+	tuiCmd := a.Command("tui", "Browse the summaries and the details of the instances in an interactive terminal UI.")
+	tuiTimeout := tuiCmd.Flag("collection.timeout", "Timeout of a collection.").Default("1m").Duration()
+
+	cmd, err := a.Parse(os.Args[1:])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error parsing commandline arguments"))
 		a.Usage(os.Args[1:])
 		os.Exit(2)
 	}
 
+	if cmd == diffCmd.FullCommand() {
+		if err := runDiff(*diffOld, *diffNew, *diffFormat, *diffChangedOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err = LoadFile(cfgFile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading configuration file"))
 		os.Exit(2)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if cmd == historyCmd.FullCommand() {
+		if *historyPath != "" {
+			cfg.HistoryConfig.Path = *historyPath
+		}
+		outCfg := OutputConfig{Format: *historyFormat, Columns: strings.Split(columns, ","), SortBy: sortBy}
+		if columns == "" {
+			outCfg.Columns = append([]string{"collected_at"}, defaultColumnNames()...)
+		}
+		if sortBy == "" {
+			outCfg.SortBy = "name"
+		}
+		if err := runHistory(cfg.HistoryConfig, outCfg, color, *historyInstances, *historySince); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	for k, v := range cfg.PrometheusConfigs {
-		wg.Add(1)
-		go func(ctx context.Context, promName string, promCfg PrometheusConfig) {
-			record := &PromSummary{
-				Name:    promName,
-				Address: promCfg.Address,
-				Status:  PromStatusOK,
-			}
-			defer func() {
-				results = append(results, record)
-				wg.Done()
-			}()
-			promAPI, err := initClient(promCfg.Address, promCfg.BasicAuth.Username,
-				promCfg.BasicAuth.Password)
-			if err != nil {
-				record.setStatus(errors.Wrapf(err, "Error initializing Prometheus API client"))
-				return
-			}
-			// Get version
-			buildInfo, err := promAPI.Buildinfo(ctx)
-			if err != nil {
-				record.setStatus(errors.Wrapf(err, "Error getting build info"))
-				return
-			}
-			record.Version = buildInfo.Version
-			// Ger number of targets
-			targets, err := promAPI.Targets(context.Background())
-			if err != nil {
-				record.setStatus(errors.Wrapf(err, "Error getting targets"))
-				return
-			}
-			record.NumOfActiveTargets = strconv.Itoa(len(targets.Active))
-			record.NumOfDroppedTargets = strconv.Itoa(len(targets.Dropped))
-			// Get storage retention
-			runtimeInfo, err := promAPI.Runtimeinfo(ctx)
-			if err != nil {
-				record.setStatus(errors.Wrapf(err, "Error getting runtime info"))
-				return
-			}
-			record.StorageRetention = runtimeInfo.StorageRetention
-			// Get number of time series
-			record.NumOfTimeSeries = strconv.Itoa(runtimeInfo.TimeSeriesCount)
-			// Get number of chunks
-			record.NumOfChunks = strconv.Itoa(runtimeInfo.ChunkCount)
-			// Get number of ingested samples per second
-			val, _, err := promAPI.Query(ctx, "rate(prometheus_tsdb_head_samples_appended_total[5m])", time.Now())
-			if err != nil {
-				record.setStatus(errors.Wrapf(err, "Error querying metrics"))
-				return
-			}
-			switch v := val.(type) {
-			case model.Vector:
-				total := 0.0
-				for _, s := range v {
-					total += float64(s.Value)
-				}
-				record.NumOfIngestedSamplesPerSec = strconv.FormatFloat(total/float64(len(v)), 'E', -1, 64)
-			default:
-				record.setStatus(errors.Errorf("unsupported type: '%q'", v))
-				return
-			}
-		}(ctx, k, v)
+	if cmd == exporterCmd.FullCommand() {
+		if err := runExporter(cfg.PrometheusConfigs, exporterCfg); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error running exporter"))
+			os.Exit(1)
+		}
+		return
 	}
 
-	wg.Wait()
+	if cmd == serveCmd.FullCommand() {
+		if err := runServer(cfg.PrometheusConfigs, serverCfg); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error running server"))
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Write the result
-	if cfg.OutputConfig.File != "" {
-		fmt.Println("You can found the report here ", cfg.OutputConfig.File)
-	}
-	switch strings.ToLower(cfg.OutputConfig.Format) {
-	case "table":
-		writer := os.Stdout
-		if cfg.OutputConfig.File != "" {
-			writer, err = os.Create(cfg.OutputConfig.File)
-			defer writer.Close()
-			if err != nil {
+	if cmd == tuiCmd.FullCommand() {
+		if err := runTUI(cfg.PrometheusConfigs, *tuiTimeout); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error running tui"))
+			os.Exit(1)
+		}
+		return
+	}
+
+	var outputs []*output
+	for _, outCfg := range cfg.OutputConfigs {
+		if columns != "" {
+			outCfg.Columns = strings.Split(columns, ",")
+		}
+		if sortBy != "" {
+			outCfg.SortBy = sortBy
+		}
+		out, err := newOutput(outCfg, color)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading output configuration"))
+			os.Exit(2)
+		}
+		outputs = append(outputs, out)
+	}
+
+	// Watch mode only redraws the table, nothing is sent nor kept.
+	if watch > 0 {
+		if len(outputs) == 0 {
+			fmt.Fprintln(os.Stderr, "Error loading output configuration: watch mode needs an output configuration")
+			os.Exit(2)
+		}
+		if err := newWatcher(outputs[0], watch, color).run(context.Background(), cfg.PrometheusConfigs); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error printing result"))
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, notifCfg := range cfg.NotificationConfigs {
+		if err := validateNotification(notifCfg, cfg.HistoryConfig); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading notification configuration"))
+			os.Exit(2)
+		}
+	}
+
+	if cfg.PushgatewayConfig != nil {
+		if err := validatePushgateway(cfg.PushgatewayConfig); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading pushgateway configuration"))
+			os.Exit(2)
+		}
+	}
+
+	for _, rwCfg := range cfg.RemoteWriteConfigs {
+		if err := validateRemoteWrite(rwCfg); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading remote write configuration"))
+			os.Exit(2)
+		}
+	}
+	for name, promCfg := range cfg.PrometheusConfigs {
+		if err := validateLabels(promCfg.Labels); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error loading configuration of %s", name))
+			os.Exit(2)
+		}
+	}
+
+	// Trend columns compare the new summaries with the history.
+	trends := trendColumns(outputs)
+	var baselines trendBaselines
+	if len(trends) > 0 {
+		if cfg.HistoryConfig.Path == "" {
+			fmt.Fprintln(os.Stderr, "Error loading output configuration: the trend columns need history_config.path")
+			os.Exit(2)
+		}
+		baselines, err = readTrendBaselines(cfg.HistoryConfig.Path, trends)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error reading history"))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Streaming formats are written as soon as each record is collected.
+	for _, out := range outputs {
+		if err := out.begin(); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error printing result"))
+		}
+	}
+	results := collectAll(ctx, cfg.PrometheusConfigs, false, func(record *PromSummary) {
+		setTrends(record, trends, baselines)
+		for _, out := range outputs {
+			if err := out.add(record); err != nil {
 				fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error printing result"))
 			}
 		}
-		table := tablewriter.NewWriter(writer)
-		table.SetHeader(headers)
-		table.SetAlignment(tablewriter.ALIGN_RIGHT)
-		for _, record := range results {
-			table.Append([]string{
-				record.Name, record.Address, record.Status.String(),
-				record.Error, record.Version, record.StorageRetention,
-				record.NumOfActiveTargets, record.NumOfDroppedTargets,
-				record.NumOfTimeSeries, record.NumOfChunks,
-				record.NumOfIngestedSamplesPerSec,
-			})
-		}
-		table.Render()
-	case "json":
-		content, _ := json.MarshalIndent(results, "", "")
-		if cfg.OutputConfig.File != "" {
-			_ = ioutil.WriteFile(cfg.OutputConfig.File, content, 0644)
+	})
+
+	// Write the result
+	for _, out := range outputs {
+		if err := out.finish(results); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error printing result"))
+			continue
+		}
+		if out.file != "" {
+			fmt.Println("You can found the report here ", out.file)
+		}
+		if out.cfg.Webhook != nil {
+			fmt.Println("The report has been sent to ", webhookDestination(out.cfg.Webhook))
+		}
+		if out.cfg.Email != nil {
+			fmt.Println("The report has been sent by email to ", strings.Join(out.cfg.Email.To, ", "))
+		}
+		if out.cfg.S3 != nil {
+			fmt.Println("The report has been uploaded to ", "s3://"+out.cfg.S3.Bucket+"/"+out.key)
+		}
+	}
+
+	// Push the metrics
+	if cfg.PushgatewayConfig != nil {
+		if err := pushMetrics(cfg.PushgatewayConfig, results); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error pushing metrics to the Pushgateway %s", cfg.PushgatewayConfig.URL))
 		} else {
-			os.Stdout.Write(content)
+			fmt.Println("The metrics have been pushed to ", cfg.PushgatewayConfig.URL)
 		}
-	case "yaml":
-		content, _ := yaml.Marshal(results)
-		if cfg.OutputConfig.File != "" {
-			_ = ioutil.WriteFile(cfg.OutputConfig.File, content, 0644)
+	}
+
+	for _, rwCfg := range cfg.RemoteWriteConfigs {
+		if err := remoteWrite(rwCfg, results, cfg.PrometheusConfigs); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error writing metrics to %s", webhookDestination(&rwCfg.Webhook)))
 		} else {
-			os.Stdout.Write(content)
-		}
-	case "csv":
-		writer := os.Stdout
-		if cfg.OutputConfig.File != "" {
-			writer, err = os.Create(cfg.OutputConfig.File)
-			defer writer.Close()
-			if err != nil {
-				fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error printing result"))
+			fmt.Println("The metrics have been written to ", webhookDestination(&rwCfg.Webhook))
+		}
+	}
+
+	// Notify the chats, before the history is updated with this run.
+	var lastRun map[string]*PromSummary
+	for _, notifCfg := range cfg.NotificationConfigs {
+		if notifCfg.OnChangeOrFailure {
+			if lastRun == nil {
+				if lastRun, err = readLastRun(cfg.HistoryConfig.Path); err != nil {
+					fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error reading history"))
+				}
+			}
+			if lastRun != nil && !hasChanged(results, lastRun) {
+				continue
 			}
 		}
-		w := csv.NewWriter(writer)
-		defer w.Flush()
+		if err := sendNotification(notifCfg, results); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error sending %s notification", notifCfg.Type))
+			continue
+		}
+		fmt.Println("The notification has been sent to ", webhookDestination(&notifCfg.Webhook))
+	}
+
+	// Keep the history
+	if cfg.HistoryConfig.Path != "" {
+		if err := appendHistory(cfg.HistoryConfig.Path, results); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrapf(err, "Error appending history"))
+		}
+	}
+}
+
+// runDiff compares two JSON reports and writes the changes to stdout.
+func runDiff(oldFile, newFile, format string, changedOnly bool) error {
+	oldResults, err := readReport(oldFile)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading report")
+	}
+	newResults, err := readReport(newFile)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading report")
+	}
+	return errors.Wrapf(writeDiff(os.Stdout, format, diffReports(oldResults, newResults), changedOnly),
+		"Error printing diff")
+}
 
-		w.Write(headers)
-		for _, record := range results {
-			w.Write([]string{
-				record.Name, record.Address, record.Status.String(),
-				record.Error, record.Version, record.StorageRetention,
-				record.NumOfActiveTargets, record.NumOfDroppedTargets,
-				record.NumOfTimeSeries, record.NumOfChunks,
-				record.NumOfIngestedSamplesPerSec,
-			})
+// runHistory queries the summaries collected since the given duration ago
+// and writes them following the output configuration.
+func runHistory(historyCfg HistoryConfig, outCfg OutputConfig, color string, instances []string, since string) error {
+	if historyCfg.Path == "" {
+		return errors.New("The history is not enabled, history_config.path is not set")
+	}
+	d, err := model.ParseDuration(since)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing duration %q", since)
+	}
+	out, err := newOutput(outCfg, color)
+	if err != nil {
+		return errors.Wrapf(err, "Error loading output configuration")
+	}
+	history, err := openHistory(historyCfg.Path)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	now := time.Now()
+	results, err := history.Query(instances, now.Add(-time.Duration(d)), now)
+	if err != nil {
+		return errors.Wrapf(err, "Error querying history")
+	}
+	if err := out.begin(); err != nil {
+		return errors.Wrapf(err, "Error printing result")
+	}
+	for _, record := range results {
+		if err := out.add(record); err != nil {
+			return errors.Wrapf(err, "Error printing result")
 		}
 	}
+	return errors.Wrapf(out.finish(results), "Error printing result")
+}
+
+// readTrendBaselines reads the baselines of the trend columns from the
+// history database, for a run starting now.
+func readTrendBaselines(path string, trends []Column) (trendBaselines, error) {
+	history, err := openHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	defer history.Close()
+	return loadTrendBaselines(history, trends, time.Now())
+}
+
+// readLastRun reads the summaries of the last run from the history database.
+func readLastRun(path string) (map[string]*PromSummary, error) {
+	history, err := openHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	defer history.Close()
+	return history.Last(time.Now())
+}
+
+// appendHistory appends the collected summaries to the history database.
+func appendHistory(path string, results []*PromSummary) error {
+	history, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	return history.Append(results)
 }
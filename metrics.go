@@ -0,0 +1,128 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsNamespace prefixes the names of the summary metrics.
+const metricsNamespace = "prom_summary"
+
+// summaryMetric is a metric of the summaries, labelled by instance name.
+// Every output exposing metrics, the 'prometheus' format, the Pushgateway,
+// remote write and the exporter, uses the same metrics.
+type summaryMetric struct {
+	desc *prometheus.Desc
+	// value returns the metric value and the extra label values, ok is
+	// false if the summary has no value.
+	value func(ps *PromSummary) (v float64, labels []string, ok bool)
+}
+
+func newSummaryMetric(name, help string, labels []string, value func(*PromSummary) (float64, []string, bool)) summaryMetric {
+	return summaryMetric{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, append([]string{"name"}, labels...), nil),
+		value: value,
+	}
+}
+
+// numberMetric returns the value of a numeric field.
+func numberMetric(field func(*PromSummary) string) func(*PromSummary) (float64, []string, bool) {
+	return func(ps *PromSummary) (float64, []string, bool) {
+		f, err := strconv.ParseFloat(field(ps), 64)
+		return f, nil, err == nil
+	}
+}
+
+var summaryMetrics = []summaryMetric{
+	newSummaryMetric("up", "Whether the summary of the Prometheus instance was collected (1) or not (0).",
+		[]string{"address"}, func(ps *PromSummary) (float64, []string, bool) {
+			if ps.Status == PromStatusOK {
+				return 1, []string{ps.Address}, true
+			}
+			return 0, []string{ps.Address}, true
+		}),
+	newSummaryMetric("build_info", "The version of the Prometheus instance, always 1.",
+		[]string{"version"}, func(ps *PromSummary) (float64, []string, bool) {
+			return 1, []string{ps.Version}, ps.Version != ""
+		}),
+	newSummaryMetric("storage_retention_seconds", "The time based storage retention of the Prometheus instance.",
+		nil, func(ps *PromSummary) (float64, []string, bool) {
+			d, ok := retentionTime(ps.StorageRetention)
+			return time.Duration(d).Seconds(), nil, ok
+		}),
+	newSummaryMetric("active_targets", "The number of active targets of the Prometheus instance.",
+		nil, numberMetric(func(ps *PromSummary) string { return ps.NumOfActiveTargets })),
+	newSummaryMetric("dropped_targets", "The number of dropped targets of the Prometheus instance.",
+		nil, numberMetric(func(ps *PromSummary) string { return ps.NumOfDroppedTargets })),
+	newSummaryMetric("time_series", "The number of time series in the head block of the Prometheus instance.",
+		nil, numberMetric(func(ps *PromSummary) string { return ps.NumOfTimeSeries })),
+	newSummaryMetric("chunks", "The number of chunks in the head block of the Prometheus instance.",
+		nil, numberMetric(func(ps *PromSummary) string { return ps.NumOfChunks })),
+	newSummaryMetric("ingested_samples_per_second", "The rate of samples ingested by the Prometheus instance.",
+		nil, numberMetric(func(ps *PromSummary) string { return ps.NumOfIngestedSamplesPerSec })),
+	newSummaryMetric("last_collection_timestamp_seconds", "The time the summary of the Prometheus instance was collected.",
+		nil, func(ps *PromSummary) (float64, []string, bool) {
+			return float64(ps.CollectedAt.UnixNano()) / 1e9, nil, !ps.CollectedAt.IsZero()
+		}),
+}
+
+// summaryCollector is a prometheus.Collector exposing the summaries as metrics.
+type summaryCollector struct {
+	results []*PromSummary
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c summaryCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range summaryMetrics {
+		ch <- m.desc
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c summaryCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, ps := range c.results {
+		for _, m := range summaryMetrics {
+			v, labels, ok := m.value(ps)
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, v, append([]string{ps.Name}, labels...)...)
+		}
+	}
+}
+
+// writeMetrics writes the summaries in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, results []*PromSummary) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(summaryCollector{results: results}); err != nil {
+		return err
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
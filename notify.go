@@ -0,0 +1,215 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// notificationTypes are the supported chat message formats.
+var notificationTypes = []string{"slack", "mattermost", "teams"}
+
+// validateNotification checks the notification configuration.
+func validateNotification(cfg NotificationConfig, history HistoryConfig) error {
+	supported := false
+	for _, t := range notificationTypes {
+		supported = supported || t == cfg.Type
+	}
+	if !supported {
+		return errors.Errorf("unsupported notification type %q, supported types: %s",
+			cfg.Type, strings.Join(notificationTypes, ", "))
+	}
+	if cfg.OnChangeOrFailure && history.Path == "" {
+		return errors.New("on_change_or_failure needs history_config.path")
+	}
+	return validateWebhook(&cfg.Webhook)
+}
+
+// hasChanged tells whether an instance is NotOK, or whether an instance
+// status has changed since the last run, or it is new. The instances which
+// are not collected anymore are ignored, last holds the last summary of
+// every instance ever collected.
+func hasChanged(results []*PromSummary, last map[string]*PromSummary) bool {
+	var lastResults []*PromSummary
+	for _, ps := range last {
+		lastResults = append(lastResults, ps)
+	}
+	for _, d := range diffReports(lastResults, results) {
+		if d.Change == ChangeAdded || d.Status != nil {
+			return true
+		}
+	}
+	for _, ps := range results {
+		if ps.Status != PromStatusOK {
+			return true
+		}
+	}
+	return false
+}
+
+// notification is the content of a chat message, independently
+// of its format.
+type notification struct {
+	Title string
+	OK    bool
+	// Facts are the fleet totals, as name and value pairs.
+	Facts [][2]string
+	// Failures are the NotOK instances, sorted by name.
+	Failures []*PromSummary
+}
+
+// newNotification summarises the results: counts, totals and NotOK instances.
+func newNotification(results []*PromSummary) *notification {
+	t := computeTotals(results)
+	n := &notification{
+		Title: fmt.Sprintf("Prometheus summary: %d instances, %d OK, %d NotOK",
+			t.NumOfInstances, t.NumOfOK, t.NumOfNotOK),
+		OK: t.NumOfNotOK == 0,
+		Facts: [][2]string{
+			{"Active targets", humanizeNumber(strconv.FormatInt(t.NumOfActiveTargets, 10))},
+			{"Dropped targets", humanizeNumber(strconv.FormatInt(t.NumOfDroppedTargets, 10))},
+			{"Time series", humanizeNumber(strconv.FormatInt(t.NumOfTimeSeries, 10))},
+			{"Samples/s", humanizeNumber(strconv.FormatFloat(t.NumOfIngestedSamplesPerSec, 'f', -1, 64))},
+		},
+	}
+	for _, ps := range results {
+		if ps.Status != PromStatusOK {
+			n.Failures = append(n.Failures, ps)
+		}
+	}
+	sort.Slice(n.Failures, func(i, j int) bool { return n.Failures[i].Name < n.Failures[j].Name })
+	return n
+}
+
+// failuresText lists the NotOK instances with their error, bold is the
+// bold markup of the chat.
+func (n *notification) failuresText(bold string) string {
+	lines := make([]string, len(n.Failures))
+	for i, ps := range n.Failures {
+		lines[i] = fmt.Sprintf("%s%s%s (%s): %s", bold, ps.Name, bold, ps.Address, ps.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text,omitempty"`
+	Fields   []slackField `json:"fields"`
+}
+
+// slackMessage is the payload of Slack and Mattermost incoming webhooks.
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts,omitempty"`
+	Text  string      `json:"text,omitempty"`
+}
+
+// teamsMessage is the MessageCard payload of Microsoft Teams incoming webhooks.
+type teamsMessage struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title"`
+	Sections   []teamsSection `json:"sections"`
+}
+
+// payload renders the notification in the chat message format.
+func (n *notification) payload(cfg NotificationConfig) interface{} {
+	switch cfg.Type {
+	case "teams":
+		color := "2EB886"
+		if !n.OK {
+			color = "D00000"
+		}
+		facts := teamsSection{}
+		for _, f := range n.Facts {
+			facts.Facts = append(facts.Facts, teamsFact{Name: f[0], Value: f[1]})
+		}
+		msg := teamsMessage{
+			Type:       "MessageCard",
+			Context:    "https://schema.org/extensions",
+			ThemeColor: color,
+			Summary:    n.Title,
+			Title:      n.Title,
+			Sections:   []teamsSection{facts},
+		}
+		if len(n.Failures) > 0 {
+			// Teams needs two line breaks to start a new line.
+			msg.Sections = append(msg.Sections, teamsSection{
+				Text: strings.Replace(n.failuresText("**"), "\n", "\n\n", -1),
+			})
+		}
+		return msg
+	default:
+		bold := "*"
+		if cfg.Type == "mattermost" {
+			bold = "**"
+		}
+		color := "good"
+		if !n.OK {
+			color = "danger"
+		}
+		attachment := slackAttachment{
+			Fallback: n.Title,
+			Color:    color,
+			Title:    n.Title,
+			Text:     n.failuresText(bold),
+		}
+		for _, f := range n.Facts {
+			attachment.Fields = append(attachment.Fields, slackField{Title: f[0], Value: f[1], Short: true})
+		}
+		return slackMessage{
+			Channel:     cfg.Channel,
+			Username:    cfg.Username,
+			Text:        n.Title,
+			Attachments: []slackAttachment{attachment},
+		}
+	}
+}
+
+// sendNotification sends the summary of the results to the chat.
+func sendNotification(cfg NotificationConfig, results []*PromSummary) error {
+	body, err := json.Marshal(newNotification(results).payload(cfg))
+	if err != nil {
+		return err
+	}
+	return sendWebhook(&cfg.Webhook, "application/json", body)
+}
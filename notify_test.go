@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHasChanged(t *testing.T) {
+	ok := func(name string) *PromSummary { return &PromSummary{Name: name, Status: PromStatusOK} }
+	notOK := func(name string) *PromSummary {
+		return &PromSummary{Name: name, Status: PromStatusNotOK, Error: "connection refused"}
+	}
+	for _, tc := range []struct {
+		name     string
+		results  []*PromSummary
+		last     []*PromSummary
+		expected bool
+	}{
+		{"unchanged", []*PromSummary{ok("p1"), ok("p2")}, []*PromSummary{ok("p1"), ok("p2")}, false},
+		{"first run", []*PromSummary{ok("p1")}, nil, true},
+		{"added", []*PromSummary{ok("p1"), ok("p2")}, []*PromSummary{ok("p1")}, true},
+		{"recovered", []*PromSummary{ok("p1")}, []*PromSummary{notOK("p1")}, true},
+		{"failed", []*PromSummary{notOK("p1")}, []*PromSummary{ok("p1")}, true},
+		{"still NotOK", []*PromSummary{ok("p1"), notOK("p2")}, []*PromSummary{ok("p1"), notOK("p2")}, true},
+		// The instances which are not collected anymore are ignored.
+		{"removed", []*PromSummary{ok("p1")}, []*PromSummary{ok("p1"), notOK("p2")}, false},
+		{
+			name:     "other changes",
+			results:  []*PromSummary{{Name: "p1", Version: "2.25.0", NumOfTimeSeries: "2000"}},
+			last:     []*PromSummary{{Name: "p1", Version: "2.24.0", NumOfTimeSeries: "1000"}},
+			expected: false,
+		},
+	} {
+		last := make(map[string]*PromSummary)
+		for _, ps := range tc.last {
+			last[ps.Name] = ps
+		}
+		if got := hasChanged(tc.results, last); got != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func testNotificationResults() []*PromSummary {
+	return []*PromSummary{
+		{Name: "p1", Address: "http://p1:9090", Status: PromStatusOK, NumOfActiveTargets: "1200",
+			NumOfTimeSeries: "2387664", NumOfIngestedSamplesPerSec: "1.5342E+04"},
+		{Name: "p3", Address: "http://p3:9090", Status: PromStatusNotOK, Error: "timeout"},
+		{Name: "p2", Address: "http://p2:9090", Status: PromStatusNotOK, Error: "connection refused"},
+	}
+}
+
+// receiveNotification sends the notification to a local receiver, and
+// returns the JSON body it received.
+func receiveNotification(t *testing.T, cfg NotificationConfig, results []*PromSummary) []byte {
+	t.Helper()
+	var (
+		body        []byte
+		contentType string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	cfg.Webhook = *testWebhookConfig(srv.URL)
+	if err := sendNotification(cfg, results); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("unexpected Content-Type %q", contentType)
+	}
+	return body
+}
+
+func TestSlackNotification(t *testing.T) {
+	for _, tc := range []struct {
+		typ  string
+		bold string
+	}{
+		{"slack", "*"},
+		{"mattermost", "**"},
+	} {
+		cfg := NotificationConfig{Type: tc.typ, Channel: "#sre", Username: "prom-summary"}
+		var msg slackMessage
+		if err := json.Unmarshal(receiveNotification(t, cfg, testNotificationResults()), &msg); err != nil {
+			t.Fatalf("%s: %s", tc.typ, err)
+		}
+		title := "Prometheus summary: 3 instances, 1 OK, 2 NotOK"
+		if msg.Channel != "#sre" || msg.Username != "prom-summary" || msg.Text != title {
+			t.Errorf("%s: unexpected message %+v", tc.typ, msg)
+		}
+		if len(msg.Attachments) != 1 {
+			t.Fatalf("%s: expected 1 attachment, got %d", tc.typ, len(msg.Attachments))
+		}
+		a := msg.Attachments[0]
+		if a.Color != "danger" || a.Title != title || a.Fallback != title {
+			t.Errorf("%s: unexpected attachment %+v", tc.typ, a)
+		}
+		// The failures are sorted by name.
+		text := tc.bold + "p2" + tc.bold + " (http://p2:9090): connection refused\n" +
+			tc.bold + "p3" + tc.bold + " (http://p3:9090): timeout"
+		if a.Text != text {
+			t.Errorf("%s: expected the text %q, got %q", tc.typ, text, a.Text)
+		}
+		fields := make(map[string]string)
+		for _, f := range a.Fields {
+			fields[f.Title] = f.Value
+		}
+		for k, v := range map[string]string{"Active targets": "1.2k", "Time series": "2.4M", "Samples/s": "15.3k"} {
+			if fields[k] != v {
+				t.Errorf("%s: expected the field %s %q, got %q", tc.typ, k, v, fields[k])
+			}
+		}
+	}
+}
+
+func TestTeamsNotification(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		results  []*PromSummary
+		color    string
+		sections int
+	}{
+		{"with NotOK", testNotificationResults(), "D00000", 2},
+		{"all OK", testNotificationResults()[:1], "2EB886", 1},
+	} {
+		var msg teamsMessage
+		if err := json.Unmarshal(receiveNotification(t, NotificationConfig{Type: "teams"}, tc.results), &msg); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if msg.Type != "MessageCard" || msg.ThemeColor != tc.color || msg.Title == "" || msg.Summary != msg.Title {
+			t.Errorf("%s: unexpected message %+v", tc.name, msg)
+		}
+		if len(msg.Sections) != tc.sections {
+			t.Fatalf("%s: expected %d sections, got %d", tc.name, tc.sections, len(msg.Sections))
+		}
+		if len(msg.Sections[0].Facts) != 4 || msg.Sections[0].Facts[2] != (teamsFact{Name: "Time series", Value: "2.4M"}) {
+			t.Errorf("%s: unexpected facts %+v", tc.name, msg.Sections[0].Facts)
+		}
+		if tc.sections == 2 && !strings.Contains(msg.Sections[1].Text, "connection refused\n\n**p3**") {
+			t.Errorf("%s: unexpected failures %q", tc.name, msg.Sections[1].Text)
+		}
+	}
+}
+
+func TestValidateNotification(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     NotificationConfig
+		history HistoryConfig
+		valid   bool
+	}{
+		{"slack", NotificationConfig{Type: "slack"}, HistoryConfig{}, true},
+		{"unsupported type", NotificationConfig{Type: "irc"}, HistoryConfig{}, false},
+		{"on change without history", NotificationConfig{Type: "teams", OnChangeOrFailure: true}, HistoryConfig{}, false},
+		{"on change", NotificationConfig{Type: "teams", OnChangeOrFailure: true}, HistoryConfig{Path: "history.db"}, true},
+	} {
+		tc.cfg.Webhook = *testWebhookConfig("https://hooks.example.com/x")
+		err := validateNotification(tc.cfg, tc.history)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/expfmt"
+	"gopkg.in/yaml.v2"
+)
+
+// formats are the supported output formats.
+var formats = []string{"table", "table-compact", "table-vertical", "json", "yaml", "csv",
+	"junit", "ndjson", "xlsx", "markdown", "html", "prometheus"}
+
+// output writes the results following an output configuration.
+type output struct {
+	cfg     OutputConfig
+	columns []Column
+	sortBy  Column
+	desc    bool
+	// file is the output file path, with its placeholders expanded.
+	file string
+	// color tells whether the table is colourised.
+	color bool
+
+	// stream is set for the streaming formats, which are written
+	// while the results are collected.
+	stream *json.Encoder
+	writer io.WriteCloser
+	// report is the rendered report, it is kept to be sent.
+	report *bytes.Buffer
+	// key is the object key of the report uploaded to S3.
+	key string
+}
+
+// newOutput validates the output configuration and returns its output.
+// colorMode is one of the Color* modes.
+func newOutput(cfg OutputConfig, colorMode string) (*output, error) {
+	o := &output{cfg: cfg}
+	o.cfg.Format = strings.ToLower(cfg.Format)
+	supported := false
+	for _, f := range formats {
+		supported = supported || f == o.cfg.Format
+	}
+	if !supported {
+		return nil, errors.Errorf("unsupported output format %q, supported formats: %s",
+			cfg.Format, strings.Join(formats, ", "))
+	}
+
+	var err error
+	if o.columns, err = parseColumns(cfg.Columns); err != nil {
+		return nil, err
+	}
+	if o.sortBy, o.desc, err = parseSort(cfg.SortBy); err != nil {
+		return nil, err
+	}
+	if err = validateThresholds(cfg.Thresholds); err != nil {
+		return nil, err
+	}
+	if cfg.File != "" {
+		if o.file, err = renderFileName(cfg.File, o.cfg.Format, time.Now()); err != nil {
+			return nil, errors.Wrapf(err, "invalid file path %q", cfg.File)
+		}
+		if cfg.KeepLast > 0 || cfg.MaxAge > 0 {
+			if _, err = newFileNamePattern(cfg.File, o.cfg.Format); err != nil {
+				return nil, errors.Wrap(err, "the old reports cannot be pruned")
+			}
+		}
+	}
+	if cfg.Webhook != nil {
+		if err = validateWebhook(cfg.Webhook); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Email != nil {
+		if err = validateEmail(cfg.Email, o.cfg.Format); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.S3 != nil {
+		if err = validateS3(cfg.S3); err != nil {
+			return nil, err
+		}
+		if o.key, err = renderFileName(cfg.S3.Key, o.cfg.Format, time.Now()); err != nil {
+			return nil, errors.Wrapf(err, "invalid s3 key %q", cfg.S3.Key)
+		}
+	}
+	o.color = strings.HasPrefix(o.cfg.Format, "table") && useColor(colorMode, o.file) && !o.sent()
+	return o, nil
+}
+
+// sent tells whether the report is sent to a webhook, by email or to S3,
+// it is then not written to stdout.
+func (o *output) sent() bool {
+	return o.cfg.Webhook != nil || o.cfg.Email != nil || o.cfg.S3 != nil
+}
+
+// open opens the output destination, the file if it is configured,
+// stdout otherwise. The report is also kept if it is sent, it is then
+// only written to stdout if no file is configured.
+func (o *output) open() (io.WriteCloser, error) {
+	w, err := o.openFile()
+	if err != nil || !o.sent() {
+		return w, err
+	}
+	o.report = &bytes.Buffer{}
+	return teeWriteCloser{Writer: io.MultiWriter(w, o.report), Closer: w}, nil
+}
+
+func (o *output) openFile() (io.WriteCloser, error) {
+	if o.file == "" {
+		if o.sent() {
+			return nopWriteCloser{ioutil.Discard}, nil
+		}
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(o.file), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(o.file)
+}
+
+// prune applies the retention policy to the previous report files.
+func (o *output) prune() error {
+	if o.file == "" || (o.cfg.KeepLast <= 0 && o.cfg.MaxAge <= 0) {
+		return nil
+	}
+	pattern, err := newFileNamePattern(o.cfg.File, o.cfg.Format)
+	if err != nil {
+		return err
+	}
+	return pruneFiles(pattern, o.file, o.cfg.KeepLast, time.Duration(o.cfg.MaxAge))
+}
+
+// begin opens the destination of the streaming formats, before the
+// results are collected.
+func (o *output) begin() error {
+	if o.cfg.Format != "ndjson" {
+		return nil
+	}
+	w, err := o.open()
+	if err != nil {
+		return err
+	}
+	o.writer = w
+	o.stream = json.NewEncoder(w)
+	return nil
+}
+
+// add is called with every record as soon as it is collected.
+// Streamed records are written in the collection order, they are not sorted.
+func (o *output) add(record *PromSummary) error {
+	if o.stream == nil {
+		return nil
+	}
+	return o.stream.Encode(filteredRecord{columns: o.columns, summary: record})
+}
+
+// finish writes the results once all of them are collected, sends
+// them to the webhook, by email and to S3, then removes the old report files.
+func (o *output) finish(results []*PromSummary) error {
+	if err := o.flush(results); err != nil {
+		return err
+	}
+	if o.cfg.Webhook != nil {
+		if err := sendWebhook(o.cfg.Webhook, contentTypes[o.cfg.Format], o.report.Bytes()); err != nil {
+			return errors.Wrap(err, "Error sending report to webhook")
+		}
+	}
+	if o.cfg.Email != nil {
+		if err := o.email(results); err != nil {
+			return errors.Wrap(err, "Error sending report by email")
+		}
+	}
+	if o.cfg.S3 != nil {
+		if err := o.upload(results); err != nil {
+			return errors.Wrap(err, "Error uploading report to S3")
+		}
+	}
+	return errors.Wrap(o.prune(), "Error removing old reports")
+}
+
+// email sends the report by email, with the CSV report as attachment.
+func (o *output) email(results []*PromSummary) error {
+	now := time.Now()
+	subject, err := emailSubject(o.cfg.Email, results, now)
+	if err != nil {
+		return err
+	}
+	var attachment []byte
+	if o.cfg.Email.AttachCSV {
+		csvOut := *o
+		csvOut.cfg.Format = "csv"
+		var b bytes.Buffer
+		if err := csvOut.write(&b, o.sorted(results)); err != nil {
+			return err
+		}
+		attachment = b.Bytes()
+	}
+	msg, err := emailMessage(o.cfg.Email, subject, emailFormats[o.cfg.Format], o.report.Bytes(), attachment, now)
+	if err != nil {
+		return err
+	}
+	return sendEmail(o.cfg.Email, msg)
+}
+
+// upload uploads the report to S3, with the run id, the number of
+// instances and the number of NotOK instances as metadata.
+func (o *output) upload(results []*PromSummary) error {
+	t := computeTotals(results)
+	metadata := map[string]string{
+		"run-id":    runID,
+		"instances": strconv.Itoa(t.NumOfInstances),
+		"not-ok":    strconv.Itoa(t.NumOfNotOK),
+	}
+	for k, v := range o.cfg.S3.Metadata {
+		metadata[k] = v
+	}
+	return newS3Client(o.cfg.S3).upload(o.key, contentTypes[o.cfg.Format], o.report.Bytes(), metadata)
+}
+
+// sorted returns a copy of the results sorted following the output configuration.
+func (o *output) sorted(results []*PromSummary) []*PromSummary {
+	sorted := make([]*PromSummary, len(results))
+	copy(sorted, results)
+	sortResults(sorted, o.sortBy, o.desc)
+	return sorted
+}
+
+func (o *output) flush(results []*PromSummary) error {
+	if o.stream != nil {
+		return o.writer.Close()
+	}
+
+	sorted := o.sorted(results)
+	w, err := o.open()
+	if err != nil {
+		return err
+	}
+	if err := o.write(w, sorted); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// write writes the results in the output format.
+func (o *output) write(w io.Writer, results []*PromSummary) error {
+	var totals *Totals
+	if o.cfg.Totals {
+		totals = computeTotals(results)
+	}
+	humanize := o.cfg.Humanize && humanFormats[o.cfg.Format]
+	switch o.cfg.Format {
+	case "table", "table-compact", "markdown":
+		table := newTable(w, o.cfg.Format)
+		if o.cfg.Format == "table-compact" {
+			table.SetHeader(shortHeaders(o.columns))
+		} else {
+			table.SetHeader(headers(o.columns))
+		}
+		for _, record := range results {
+			if o.color {
+				table.Rich(row(o.columns, record, humanize), rowColors(o.columns, o.cfg.Thresholds, record))
+				continue
+			}
+			table.Append(row(o.columns, record, humanize))
+		}
+		if totals != nil {
+			table.Append(totalsRow(o.columns, totals, humanize))
+		}
+		table.Render()
+		return nil
+	case "table-vertical":
+		var (
+			rows   [][]string
+			colors [][]tablewriter.Colors
+		)
+		for _, record := range results {
+			rows = append(rows, row(o.columns, record, humanize))
+			colors = append(colors, rowColors(o.columns, o.cfg.Thresholds, record))
+		}
+		if totals != nil {
+			rows = append(rows, totalsRow(o.columns, totals, humanize))
+			colors = append(colors, make([]tablewriter.Colors, len(o.columns)))
+		}
+		if !o.color {
+			colors = nil
+		}
+		width := 0
+		if o.file == "" && !o.sent() {
+			width = terminalWidth()
+		}
+		return writeVertical(w, o.columns, rows, colors, width)
+	case "html":
+		return writeHTML(w, o.columns, results, totals, humanize)
+	case "json":
+		var report interface{} = filterRecords(o.columns, results)
+		if totals != nil {
+			report = reportWithTotals{Results: filterRecords(o.columns, results), Totals: totals}
+		}
+		content, err := json.MarshalIndent(report, "", "")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case "yaml":
+		var report interface{} = filterRecords(o.columns, results)
+		if totals != nil {
+			report = reportWithTotals{Results: filterRecords(o.columns, results), Totals: totals}
+		}
+		content, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(headers(o.columns))
+		for _, record := range results {
+			cw.Write(row(o.columns, record, false))
+		}
+		if totals != nil {
+			cw.Write(totalsRow(o.columns, totals, false))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "junit":
+		return writeJUnit(w, o.columns, results)
+	case "xlsx":
+		return writeXLSX(w, o.columns, results)
+	case "prometheus":
+		return writeMetrics(w, results)
+	default:
+		return errors.Errorf("unsupported output format %q", o.cfg.Format)
+	}
+}
+
+// newTable returns a table writer styled for the given tabular format:
+// 'table', 'table-compact' or 'markdown'.
+func newTable(w io.Writer, format string) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	switch format {
+	case "table-compact":
+		// Borderless, like kubectl get.
+		table.SetAutoWrapText(false)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetBorder(false)
+		table.SetHeaderLine(false)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetTablePadding("   ")
+		table.SetNoWhiteSpace(true)
+	case "markdown":
+		table.SetAutoFormatHeaders(false)
+		table.SetAutoWrapText(false)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+	}
+	return table
+}
+
+// contentTypes are the MIME types of the output formats.
+var contentTypes = map[string]string{
+	"table":          "text/plain; charset=utf-8",
+	"table-compact":  "text/plain; charset=utf-8",
+	"table-vertical": "text/plain; charset=utf-8",
+	"json":           "application/json",
+	"yaml":           "application/yaml",
+	"csv":            "text/csv; charset=utf-8",
+	"junit":          "application/xml",
+	"ndjson":         "application/x-ndjson",
+	"xlsx":           "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"markdown":       "text/markdown; charset=utf-8",
+	"html":           "text/html; charset=utf-8",
+	"prometheus":     string(expfmt.FmtText),
+}
+
+// teeWriteCloser closes the destination of a multi writer.
+type teeWriteCloser struct {
+	io.Writer
+	io.Closer
+}
+
+// nopWriteCloser does not close the underlying writer, it is used for stdout.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,55 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// validatePushgateway checks the Pushgateway configuration.
+func validatePushgateway(cfg *PushgatewayConfig) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid pushgateway url %q", cfg.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("invalid pushgateway url %q, the scheme must be http or https", cfg.URL)
+	}
+	if cfg.Job == "" {
+		return errors.New("no pushgateway job")
+	}
+	return nil
+}
+
+// pushMetrics pushes the summary metrics to the Pushgateway. The metrics
+// of the group are replaced, the instances which are not collected anymore
+// are removed.
+func pushMetrics(cfg *PushgatewayConfig, results []*PromSummary) error {
+	pusher := push.New(cfg.URL, cfg.Job).
+		Collector(summaryCollector{results: results}).
+		Client(&http.Client{Timeout: time.Duration(cfg.Timeout)})
+	for k, v := range cfg.Grouping {
+		pusher = pusher.Grouping(k, v)
+	}
+	if cfg.BasicAuth.Username != "" {
+		pusher = pusher.BasicAuth(cfg.BasicAuth.Username, cfg.BasicAuth.Password)
+	}
+	return pusher.Push()
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// validateLabels checks the label names.
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || name == model.MetricNameLabel {
+			return errors.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+// validateRemoteWrite checks the remote write configuration.
+func validateRemoteWrite(cfg RemoteWriteConfig) error {
+	if err := validateLabels(cfg.ExternalLabels); err != nil {
+		return errors.Wrap(err, "invalid external labels")
+	}
+	return validateWebhook(&cfg.Webhook)
+}
+
+// labelPair is a label of a series.
+type labelPair struct {
+	name, value string
+}
+
+// remoteWriteSample is a sample of a series, its labels are sorted by name.
+type remoteWriteSample struct {
+	labels    []labelPair
+	value     float64
+	timestamp int64
+}
+
+// remoteWriteSamples returns the summary metrics as samples, timestamped
+// with the collection time of each summary. The labels of the instance
+// configuration and the external labels are added, the metric labels take
+// precedence over the instance labels which take precedence over the
+// external labels.
+func remoteWriteSamples(results []*PromSummary, promCfgs map[string]PrometheusConfig,
+	external map[string]string) ([]remoteWriteSample, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(summaryCollector{results: results}); err != nil {
+		return nil, err
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	collectedAt := make(map[string]time.Time, len(results))
+	for _, ps := range results {
+		collectedAt[ps.Name] = ps.CollectedAt
+	}
+
+	var samples []remoteWriteSample
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for k, v := range external {
+				labels[k] = v
+			}
+			var name string
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "name" {
+					name = lp.GetValue()
+				}
+			}
+			for k, v := range promCfgs[name].Labels {
+				labels[k] = v
+			}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			labels[model.MetricNameLabel] = mf.GetName()
+
+			s := remoteWriteSample{value: m.GetGauge().GetValue()}
+			for k, v := range labels {
+				s.labels = append(s.labels, labelPair{name: k, value: v})
+			}
+			sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+			ts := collectedAt[name]
+			if ts.IsZero() {
+				ts = time.Now()
+			}
+			s.timestamp = ts.UnixNano() / int64(time.Millisecond)
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
+
+// encodeWriteRequest encodes the samples as a remote write WriteRequest
+// protobuf message, one TimeSeries per sample:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(samples []remoteWriteSample) []byte {
+	var req []byte
+	for _, s := range samples {
+		var series []byte
+		for _, lp := range s.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, lp.name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, lp.value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, series)
+	}
+	return req
+}
+
+// remoteWrite sends the summary metrics with the remote write protocol,
+// a snappy compressed WriteRequest.
+func remoteWrite(cfg RemoteWriteConfig, results []*PromSummary, promCfgs map[string]PrometheusConfig) error {
+	samples, err := remoteWriteSamples(results, promCfgs, cfg.ExternalLabels)
+	if err != nil {
+		return err
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(samples))
+
+	webhook := cfg.Webhook
+	webhook.Method = "POST"
+	webhook.Headers = map[string]string{
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+	for k, v := range cfg.Webhook.Headers {
+		webhook.Headers[k] = v
+	}
+	return sendWebhook(&webhook, "application/x-protobuf", body)
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodedSeries is a TimeSeries of a decoded WriteRequest.
+type decodedSeries struct {
+	labels  []labelPair
+	samples []decodedSample
+}
+
+type decodedSample struct {
+	value     float64
+	timestamp int64
+}
+
+// field consumes a field of a protobuf message, and returns its number,
+// its type and its value: the integer of a varint or fixed64, the bytes
+// of a length-delimited field.
+func field(b []byte) (protowire.Number, protowire.Type, uint64, []byte, int, error) {
+	num, typ, n := protowire.ConsumeTag(b)
+	if n < 0 {
+		return 0, 0, 0, nil, 0, protowire.ParseError(n)
+	}
+	var (
+		v     uint64
+		bytes []byte
+		m     int
+	)
+	switch typ {
+	case protowire.VarintType:
+		v, m = protowire.ConsumeVarint(b[n:])
+	case protowire.Fixed64Type:
+		v, m = protowire.ConsumeFixed64(b[n:])
+	case protowire.BytesType:
+		bytes, m = protowire.ConsumeBytes(b[n:])
+	default:
+		return 0, 0, 0, nil, 0, errors.Errorf("unexpected wire type %d", typ)
+	}
+	if m < 0 {
+		return 0, 0, 0, nil, 0, protowire.ParseError(m)
+	}
+	return num, typ, v, bytes, n + m, nil
+}
+
+// decodeWriteRequest decodes a WriteRequest, it fails on any field which
+// is not expected by the remote write protocol.
+func decodeWriteRequest(b []byte) ([]decodedSeries, error) {
+	var req []decodedSeries
+	for len(b) > 0 {
+		num, typ, _, series, n, err := field(b)
+		if err != nil {
+			return nil, err
+		}
+		if num != 1 || typ != protowire.BytesType {
+			return nil, errors.Errorf("unexpected WriteRequest field %d", num)
+		}
+		b = b[n:]
+
+		var ts decodedSeries
+		for len(series) > 0 {
+			num, typ, _, msg, n, err := field(series)
+			if err != nil {
+				return nil, err
+			}
+			series = series[n:]
+			switch {
+			case num == 1 && typ == protowire.BytesType:
+				var lp labelPair
+				for len(msg) > 0 {
+					num, typ, _, s, n, err := field(msg)
+					if err != nil {
+						return nil, err
+					}
+					msg = msg[n:]
+					switch {
+					case num == 1 && typ == protowire.BytesType:
+						lp.name = string(s)
+					case num == 2 && typ == protowire.BytesType:
+						lp.value = string(s)
+					default:
+						return nil, errors.Errorf("unexpected Label field %d", num)
+					}
+				}
+				ts.labels = append(ts.labels, lp)
+			case num == 2 && typ == protowire.BytesType:
+				var s decodedSample
+				for len(msg) > 0 {
+					num, typ, v, _, n, err := field(msg)
+					if err != nil {
+						return nil, err
+					}
+					msg = msg[n:]
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						s.value = math.Float64frombits(v)
+					case num == 2 && typ == protowire.VarintType:
+						s.timestamp = int64(v)
+					default:
+						return nil, errors.Errorf("unexpected Sample field %d", num)
+					}
+				}
+				ts.samples = append(ts.samples, s)
+			default:
+				return nil, errors.Errorf("unexpected TimeSeries field %d", num)
+			}
+		}
+		req = append(req, ts)
+	}
+	return req, nil
+}
+
+func TestRemoteWrite(t *testing.T) {
+	var (
+		req  *http.Request
+		body []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	collectedAt := time.Date(2021, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	results := []*PromSummary{
+		{Name: "p1", Address: "http://p1:9090", Status: PromStatusOK, Version: "2.25.0",
+			NumOfTimeSeries: "1000", CollectedAt: collectedAt},
+		{Name: "p2", Address: "http://p2:9090", Status: PromStatusNotOK,
+			CollectedAt: collectedAt.Add(time.Second)},
+	}
+	promCfgs := map[string]PrometheusConfig{
+		"p1": {Labels: map[string]string{"dc": "paris", "env": "prod"}},
+		"p2": {Labels: map[string]string{"dc": "lyon"}},
+	}
+	cfg := DefaultRemoteWriteConfig
+	cfg.Webhook.URL = srv.URL + "/api/v1/write"
+	cfg.Webhook.Timeout = model.Duration(5 * time.Second)
+	cfg.Webhook.Headers = map[string]string{"X-Scope-OrgID": "sre"}
+	// The instance labels take precedence over the external labels.
+	cfg.ExternalLabels = map[string]string{"env": "staging", "region": "eu"}
+
+	if err := remoteWrite(cfg, results, promCfgs); err != nil {
+		t.Fatal(err)
+	}
+	if req == nil {
+		t.Fatal("no request received")
+	}
+	if req.Method != "POST" || req.URL.Path != "/api/v1/write" {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+	}
+	for k, v := range map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"X-Scope-OrgID":                     "sre",
+	} {
+		if got := req.Header.Get(k); got != v {
+			t.Errorf("expected %s %q, got %q", k, v, got)
+		}
+	}
+
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := decodeWriteRequest(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]decodedSeries)
+	for _, ts := range series {
+		if !sort.SliceIsSorted(ts.labels, func(i, j int) bool { return ts.labels[i].name < ts.labels[j].name }) {
+			t.Errorf("labels not sorted: %v", ts.labels)
+		}
+		if len(ts.samples) != 1 {
+			t.Errorf("expected 1 sample, got %d", len(ts.samples))
+			continue
+		}
+		var key string
+		for _, lp := range ts.labels {
+			if lp.name == model.MetricNameLabel || lp.name == "name" {
+				key += lp.value + "/"
+			}
+		}
+		got[key] = ts
+	}
+
+	for _, tc := range []struct {
+		key       string
+		labels    []labelPair
+		value     float64
+		timestamp int64
+	}{
+		{
+			key: "prom_summary_up/p1/",
+			labels: []labelPair{
+				{"__name__", "prom_summary_up"}, {"address", "http://p1:9090"}, {"dc", "paris"},
+				{"env", "prod"}, {"name", "p1"}, {"region", "eu"},
+			},
+			value:     1,
+			timestamp: 1614600000123,
+		},
+		{
+			key: "prom_summary_up/p2/",
+			labels: []labelPair{
+				{"__name__", "prom_summary_up"}, {"address", "http://p2:9090"}, {"dc", "lyon"},
+				{"env", "staging"}, {"name", "p2"}, {"region", "eu"},
+			},
+			value:     0,
+			timestamp: 1614600001123,
+		},
+		{
+			key: "prom_summary_time_series/p1/",
+			labels: []labelPair{
+				{"__name__", "prom_summary_time_series"}, {"dc", "paris"},
+				{"env", "prod"}, {"name", "p1"}, {"region", "eu"},
+			},
+			value:     1000,
+			timestamp: 1614600000123,
+		},
+	} {
+		ts, ok := got[tc.key]
+		if !ok {
+			t.Errorf("%s: series not found", tc.key)
+			continue
+		}
+		if len(ts.labels) != len(tc.labels) {
+			t.Errorf("%s: expected the labels %v, got %v", tc.key, tc.labels, ts.labels)
+			continue
+		}
+		for i := range tc.labels {
+			if ts.labels[i] != tc.labels[i] {
+				t.Errorf("%s: expected the labels %v, got %v", tc.key, tc.labels, ts.labels)
+				break
+			}
+		}
+		if s := ts.samples[0]; s.value != tc.value || s.timestamp != tc.timestamp {
+			t.Errorf("%s: expected the sample %v@%d, got %v@%d", tc.key, tc.value, tc.timestamp, s.value, s.timestamp)
+		}
+	}
+	if _, ok := got["prom_summary_time_series/p2/"]; ok {
+		t.Error("unexpected time series of the NotOK instance")
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	for _, tc := range []struct {
+		labels map[string]string
+		valid  bool
+	}{
+		{map[string]string{"dc": "paris", "env_1": "prod"}, true},
+		{nil, true},
+		{map[string]string{"__name__": "x"}, false},
+		{map[string]string{"1dc": "paris"}, false},
+		{map[string]string{"d-c": "paris"}, false},
+	} {
+		err := validateLabels(tc.labels)
+		if tc.valid && err != nil {
+			t.Errorf("%v: unexpected error: %s", tc.labels, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%v: expected an error", tc.labels)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 // PromSummary is the result format
@@ -31,6 +32,61 @@ type PromSummary struct {
 	NumOfTimeSeries            string     `json:"number_of_time_series" yaml:"number_of_time_series"`
 	NumOfChunks                string     `json:"number_of_chunks" yaml:"number_of_chunks"`
 	NumOfIngestedSamplesPerSec string     `json:"number_of_ingested_samples_per_seconds" yaml:"number_of_ingested_samples_per_seconds"`
+	CollectedAt                time.Time  `json:"collected_at" yaml:"collected_at"`
+	// Labels are the labels of the instance configuration.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Details holds the detail sections, they are not part of the
+	// summary row, only the formats which can render them use them.
+	Details *PromDetails `json:"-" yaml:"-"`
+	// Trends are the values of the trend columns, by column name.
+	Trends map[string]string `json:"-" yaml:"-"`
+}
+
+// PromDetails are the detail sections collected from a Prometheus instance.
+type PromDetails struct {
+	// TargetsByJob is the number of active targets per job and health.
+	TargetsByJob []JobTargets `json:"targets_by_job"`
+	// RuleGroups are the rule groups, with the health of their rules.
+	RuleGroups []RuleGroupStats `json:"rule_groups"`
+	// TopMetrics are the metric names with the highest number of series.
+	TopMetrics []MetricCardinality `json:"top_metrics"`
+	// Runtime is the runtime information of the Prometheus process.
+	Runtime *RuntimeInfo `json:"runtime,omitempty"`
+}
+
+// JobTargets is the number of active targets of a job, grouped by health.
+type JobTargets struct {
+	Job     string `json:"job"`
+	Up      int    `json:"up"`
+	Down    int    `json:"down"`
+	Unknown int    `json:"unknown"`
+}
+
+// RuleGroupStats is the number of rules of a rule group, the unhealthy
+// ones and the firing alerts.
+type RuleGroupStats struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Rules     int    `json:"rules"`
+	Unhealthy int    `json:"unhealthy"`
+	Firing    int    `json:"firing"`
+}
+
+// RuntimeInfo is the runtime information of a Prometheus process.
+type RuntimeInfo struct {
+	StartTime           time.Time `json:"start_time"`
+	ReloadConfigSuccess bool      `json:"reload_config_success"`
+	LastConfigTime      time.Time `json:"last_config_time"`
+	CorruptionCount     int       `json:"corruption_count"`
+	GoroutineCount      int       `json:"goroutine_count"`
+	GOMAXPROCS          int       `json:"gomaxprocs"`
+	GOGC                string    `json:"gogc"`
+}
+
+// MetricCardinality is the number of series of a metric name.
+type MetricCardinality struct {
+	Name   string `json:"name"`
+	Series uint64 `json:"series"`
 }
 
 // PromStatus is the state of the Prometheus endpoint, if there
@@ -0,0 +1,353 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/pkg/errors"
+)
+
+// minPartSize is the minimum size of the parts of a multipart upload,
+// but the last one.
+const minPartSize = 5 * units.MiB
+
+// runID identifies the run in the metadata of the uploaded reports.
+var runID = newRunID()
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validateS3 checks the S3 configuration.
+func validateS3(cfg *S3Config) error {
+	if cfg.Bucket == "" {
+		return errors.New("no s3 bucket")
+	}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return errors.Wrapf(err, "invalid s3 endpoint %q", cfg.Endpoint)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.Errorf("invalid s3 endpoint %q, the scheme must be http or https", cfg.Endpoint)
+		}
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return errors.New("no s3 credentials, access_key_id and secret_access_key are required")
+	}
+	if cfg.PartSize < minPartSize {
+		return errors.Errorf("invalid s3 part_size %s, the minimum is %s", cfg.PartSize, minPartSize)
+	}
+	return nil
+}
+
+// s3Client uploads objects to an S3-compatible object storage, its
+// requests are signed with AWS Signature Version 4.
+type s3Client struct {
+	cfg    *S3Config
+	client *http.Client
+}
+
+func newS3Client(cfg *S3Config) *s3Client {
+	return &s3Client{cfg: cfg, client: &http.Client{Timeout: time.Duration(cfg.Timeout)}}
+}
+
+// upload uploads the object, in parts if it is larger than the part size.
+// The metadata keys are sent as 'x-amz-meta-<key>' headers.
+func (c *s3Client) upload(key, contentType string, body []byte, metadata map[string]string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	for k, v := range metadata {
+		header.Set("X-Amz-Meta-"+k, v)
+	}
+	if int64(len(body)) <= int64(c.cfg.PartSize) {
+		_, err := c.do("PUT", key, nil, header, body)
+		return err
+	}
+	return c.multipartUpload(key, header, body)
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// multipartUpload uploads the object in parts, the upload is aborted if a
+// part cannot be uploaded.
+func (c *s3Client) multipartUpload(key string, header http.Header, body []byte) error {
+	resp, err := c.do("POST", key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return errors.Wrap(err, "Error initiating multipart upload")
+	}
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal(resp, &initiated); err != nil || initiated.UploadID == "" {
+		return errors.Errorf("Error initiating multipart upload, invalid response: %s", resp)
+	}
+
+	complete := completeMultipartUpload{}
+	partSize := int(c.cfg.PartSize)
+	for i := 0; i*partSize < len(body); i++ {
+		end := (i + 1) * partSize
+		if end > len(body) {
+			end = len(body)
+		}
+		etag, err := c.uploadPart(key, initiated.UploadID, i+1, body[i*partSize:end])
+		if err != nil {
+			c.do("DELETE", key, url.Values{"uploadId": {initiated.UploadID}}, nil, nil)
+			return errors.Wrapf(err, "Error uploading part %d", i+1)
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: i + 1, ETag: etag})
+	}
+
+	content, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err = c.do("POST", key, url.Values{"uploadId": {initiated.UploadID}}, nil, content)
+	if err != nil {
+		return errors.Wrap(err, "Error completing multipart upload")
+	}
+	// The completion may fail after a 200 OK response.
+	var s3Err s3Error
+	if xml.Unmarshal(resp, &s3Err) == nil && s3Err.Code != "" {
+		return errors.Wrap(s3Err, "Error completing multipart upload")
+	}
+	return nil
+}
+
+func (c *s3Client) uploadPart(key, uploadID string, number int, part []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	req, err := c.newRequest("PUT", key, query, nil, part)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// s3Error is the error response of the S3 API.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// do sends a signed request and returns the response body.
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	req, err := c.newRequest(method, key, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// send sends the request, the non 2xx responses are returned as errors.
+func (c *s3Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	content, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var s3Err s3Error
+	if xml.Unmarshal(content, &s3Err) == nil && s3Err.Code != "" {
+		return nil, errors.Wrap(s3Err, resp.Status)
+	}
+	return nil, errors.Errorf("%s: %s", resp.Status, bytes.TrimSpace(content))
+}
+
+// objectURL returns the URL of the object, path-style or virtual-hosted.
+func (c *s3Client) objectURL(key string) (*url.URL, error) {
+	endpoint := c.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", c.cfg.Region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + strings.TrimPrefix(key, "/")
+	if c.cfg.PathStyle {
+		path = "/" + c.cfg.Bucket + path
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = s3Escape(u.Path, false)
+	return u, nil
+}
+
+func (c *s3Client) newRequest(method, key string, query url.Values, header http.Header, body []byte) (*http.Request, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signV4(req, body, c.cfg, time.Now().UTC())
+	return req, nil
+}
+
+// signV4 signs the request with AWS Signature Version 4, the payload is
+// signed as well.
+func signV4(req *http.Request, body []byte, cfg *S3Config, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		values := req.Header[http.CanonicalHeaderKey(name)]
+		for i, v := range values {
+			values[i] = strings.TrimSpace(v)
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.Join(values, ","))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, cfg.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature))
+	// The Host header is sent from req.Host, not from the headers.
+	req.Header.Del("Host")
+}
+
+// canonicalQuery encodes the query sorted by key, as required by the signature.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes all the characters but the unreserved ones,
+// the slashes are kept unless encodeSlash is true.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ServerConfig are the settings of the serve command.
+type ServerConfig struct {
+	ListenAddress string
+	// Interval is the refresh interval of the summaries.
+	Interval time.Duration
+	// Timeout is the timeout of a collection.
+	Timeout time.Duration
+}
+
+// apiSummary is a summary with its detail sections, as returned
+// by /api/v1/summaries/{name}.
+type apiSummary struct {
+	*PromSummary
+	Details *PromDetails `json:"details,omitempty"`
+}
+
+// apiServer serves the last summaries as JSON.
+type apiServer struct {
+	cache *summaryCache
+}
+
+// handleSummaries serves GET /api/v1/summaries, the summaries sorted by name.
+func (s *apiServer) handleSummaries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	results, _, _ := s.cache.summaries()
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleSummary serves GET /api/v1/summaries/{name}, the summary of an
+// instance with its detail sections.
+func (s *apiServer) handleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/summaries/")
+	results, _, _ := s.cache.summaries()
+	for _, ps := range results {
+		if ps.Name == name {
+			writeJSON(w, http.StatusOK, apiSummary{PromSummary: ps, Details: ps.Details})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "unknown instance %q", name)
+}
+
+// handleRefresh serves POST /api/v1/refresh, it collects the summaries
+// now and returns them.
+func (s *apiServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.cache.refresh())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if results, ok := v.([]*PromSummary); ok && results == nil {
+		v = []*PromSummary{}
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%s", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	content, _ := json.Marshal(map[string]string{"error": fmt.Sprintf(format, args...)})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+// runServer refreshes the summaries on a schedule and serves them with
+// a REST API, as metrics on /metrics and with a web dashboard on /.
+func runServer(promCfgs map[string]PrometheusConfig, cfg ServerConfig) error {
+	if cfg.Interval <= 0 {
+		return errors.Errorf("invalid refresh interval %s, it must be positive", cfg.Interval)
+	}
+	cache := newSummaryCache(promCfgs, cfg.Interval, 0, cfg.Timeout, true)
+	go cache.run(context.Background())
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(&exporter{cache: cache}); err != nil {
+		return err
+	}
+
+	api := &apiServer{cache: cache}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/summaries", api.handleSummaries)
+	mux.HandleFunc("/api/v1/summaries/", api.handleSummary)
+	mux.HandleFunc("/api/v1/refresh", api.handleRefresh)
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	dash := &dashboard{cache: cache}
+	mux.HandleFunc("/", dash.handleIndex)
+	mux.HandleFunc(dashboardPath, dash.handleInstance)
+	mux.HandleFunc("/static/style.css", dash.handleStyle)
+
+	fmt.Fprintln(os.Stderr, "Listening on", cfg.ListenAddress)
+	return http.ListenAndServe(cfg.ListenAddress, mux)
+}
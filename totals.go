@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Totals are the fleet totals, aggregated over all Prometheus instances.
+type Totals struct {
+	NumOfInstances             int     `json:"number_of_instances" yaml:"number_of_instances"`
+	NumOfOK                    int     `json:"number_of_ok" yaml:"number_of_ok"`
+	NumOfNotOK                 int     `json:"number_of_not_ok" yaml:"number_of_not_ok"`
+	MinStorageRetention        string  `json:"min_storage_retention" yaml:"min_storage_retention"`
+	MaxStorageRetention        string  `json:"max_storage_retention" yaml:"max_storage_retention"`
+	NumOfActiveTargets         int64   `json:"number_of_active_targets" yaml:"number_of_active_targets"`
+	NumOfDroppedTargets        int64   `json:"number_of_dropped_targets" yaml:"number_of_dropped_targets"`
+	NumOfTimeSeries            int64   `json:"number_of_time_series" yaml:"number_of_time_series"`
+	NumOfChunks                int64   `json:"number_of_chunks" yaml:"number_of_chunks"`
+	NumOfIngestedSamplesPerSec float64 `json:"number_of_ingested_samples_per_seconds" yaml:"number_of_ingested_samples_per_seconds"`
+}
+
+// computeTotals aggregates the results. The values which cannot be
+// parsed, for example the ones of NotOK instances, and the non-finite
+// ones are ignored.
+func computeTotals(results []*PromSummary) *Totals {
+	t := &Totals{NumOfInstances: len(results)}
+	var minRetention, maxRetention model.Duration
+	for _, ps := range results {
+		if ps.Status == PromStatusOK {
+			t.NumOfOK++
+		} else {
+			t.NumOfNotOK++
+		}
+		t.NumOfActiveTargets += parseInt(ps.NumOfActiveTargets)
+		t.NumOfDroppedTargets += parseInt(ps.NumOfDroppedTargets)
+		t.NumOfTimeSeries += parseInt(ps.NumOfTimeSeries)
+		t.NumOfChunks += parseInt(ps.NumOfChunks)
+		// The rate is NaN when the instance has not ingested any sample.
+		if isFinite(ps.NumOfIngestedSamplesPerSec) {
+			f, _ := strconv.ParseFloat(ps.NumOfIngestedSamplesPerSec, 64)
+			t.NumOfIngestedSamplesPerSec += f
+		}
+		if d, ok := retentionTime(ps.StorageRetention); ok {
+			if t.MinStorageRetention == "" || d < minRetention {
+				minRetention, t.MinStorageRetention = d, d.String()
+			}
+			if t.MaxStorageRetention == "" || d > maxRetention {
+				maxRetention, t.MaxStorageRetention = d, d.String()
+			}
+		}
+	}
+	return t
+}
+
+// retentionTime returns the time based part of a storage retention,
+// for example '15d' of '15d or 10GiB'.
+func retentionTime(retention string) (model.Duration, bool) {
+	for _, part := range strings.Split(retention, " or ") {
+		if d, err := model.ParseDuration(strings.TrimSpace(part)); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func parseInt(s string) int64 {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// totalsRow returns the totals as a row of the given columns, for the
+// tabular formats. The columns which cannot be aggregated are left empty.
+func totalsRow(columns []Column, t *Totals, humanize bool) []string {
+	format := func(c Column, v string) string {
+		if humanize {
+			return c.Humanize(v)
+		}
+		return v
+	}
+	r := make([]string, len(columns))
+	for i, c := range columns {
+		switch c.Name {
+		case "name":
+			r[i] = "total"
+		case "status":
+			r[i] = fmt.Sprintf("OK: %d, NotOK: %d", t.NumOfOK, t.NumOfNotOK)
+		case "storage_retention":
+			if t.MinStorageRetention != "" {
+				r[i] = fmt.Sprintf("min: %s, max: %s", format(c, t.MinStorageRetention),
+					format(c, t.MaxStorageRetention))
+			}
+		case "number_of_active_targets":
+			r[i] = strconv.FormatInt(t.NumOfActiveTargets, 10)
+		case "number_of_dropped_targets":
+			r[i] = strconv.FormatInt(t.NumOfDroppedTargets, 10)
+		case "number_of_time_series":
+			r[i] = strconv.FormatInt(t.NumOfTimeSeries, 10)
+		case "number_of_chunks":
+			r[i] = strconv.FormatInt(t.NumOfChunks, 10)
+		case "number_of_ingested_samples_per_seconds":
+			r[i] = strconv.FormatFloat(t.NumOfIngestedSamplesPerSec, 'E', -1, 64)
+		}
+		if c.Name != "storage_retention" {
+			r[i] = format(c, r[i])
+		}
+	}
+	return r
+}
+
+// reportWithTotals is the JSON/YAML document written when the totals are enabled.
+type reportWithTotals struct {
+	Results []filteredRecord `json:"results" yaml:"results"`
+	Totals  *Totals          `json:"totals" yaml:"totals"`
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testTotalsResults() []*PromSummary {
+	return []*PromSummary{
+		{Name: "p1", Status: PromStatusOK, StorageRetention: "15d or 10GiB", NumOfActiveTargets: "10",
+			NumOfTimeSeries: "1000", NumOfIngestedSamplesPerSec: "1.5E+02"},
+		// The rate query of an instance which has not ingested any sample
+		// returns an empty vector.
+		{Name: "p2", Status: PromStatusOK, StorageRetention: "90d", NumOfActiveTargets: "5",
+			NumOfTimeSeries: "500", NumOfIngestedSamplesPerSec: "NaN"},
+		{Name: "p3", Status: PromStatusOK, StorageRetention: "10GiB", NumOfIngestedSamplesPerSec: "+Inf"},
+		{Name: "p4", Status: PromStatusNotOK, Error: "connection refused"},
+	}
+}
+
+func TestComputeTotals(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		results  []*PromSummary
+		expected Totals
+	}{
+		{"no instance", nil, Totals{}},
+		{
+			name:    "with NaN and NotOK",
+			results: testTotalsResults(),
+			expected: Totals{
+				NumOfInstances:             4,
+				NumOfOK:                    3,
+				NumOfNotOK:                 1,
+				MinStorageRetention:        "15d",
+				MaxStorageRetention:        "90d",
+				NumOfActiveTargets:         15,
+				NumOfTimeSeries:            1500,
+				NumOfIngestedSamplesPerSec: 150,
+			},
+		},
+	} {
+		if got := computeTotals(tc.results); *got != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.expected, *got)
+		}
+	}
+}
+
+func TestTotalsRow(t *testing.T) {
+	columns, err := parseColumns([]string{
+		"name", "status", "version", "storage_retention",
+		"number_of_time_series", "number_of_ingested_samples_per_seconds",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals := computeTotals(testTotalsResults())
+	for _, tc := range []struct {
+		humanize bool
+		expected []string
+	}{
+		{false, []string{"total", "OK: 3, NotOK: 1", "", "min: 15d, max: 90d", "1500", "1.5E+02"}},
+		{true, []string{"total", "OK: 3, NotOK: 1", "", "min: 15 days, max: 90 days", "1.5k", "150"}},
+	} {
+		got := totalsRow(columns, totals, tc.humanize)
+		if strings.Join(got, "|") != strings.Join(tc.expected, "|") {
+			t.Errorf("humanize %t: expected %q, got %q", tc.humanize, tc.expected, got)
+		}
+	}
+}
+
+func TestWriteTotals(t *testing.T) {
+	for _, format := range []string{"json", "yaml", "csv", "table"} {
+		outCfg := DefaultOutputConfig
+		outCfg.Format, outCfg.Totals = format, true
+		out, err := newOutput(outCfg, ColorNever)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := out.write(&b, testTotalsResults()); err != nil {
+			t.Errorf("%s: unexpected error: %s", format, err)
+			continue
+		}
+		// The NaN rate of an instance is kept, but not in the totals.
+		report := b.String()
+		if strings.Count(strings.ToLower(report), "nan") != 1 {
+			t.Errorf("%s: expected a single NaN value:\n%s", format, report)
+		}
+		if format == "json" {
+			var decoded struct {
+				Totals Totals `json:"totals"`
+			}
+			if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Totals.NumOfIngestedSamplesPerSec != 150 {
+				t.Errorf("unexpected totals %+v", decoded.Totals)
+			}
+		}
+	}
+}
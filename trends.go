@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Kinds of trend columns, appended to the name of a numeric column.
+const (
+	trendChange = "_change"
+	trendGrowth = "_growth"
+)
+
+// trend is the definition of a trend column: the change of a numeric
+// column compared to a previous run, in absolute value or in percent.
+type trend struct {
+	base    Column
+	percent bool
+	// since is how long ago the compared run was collected,
+	// 0 means the last run.
+	since model.Duration
+}
+
+// trendColumn returns the trend column of the given name, which is either
+// '<column>_change[_<duration>]' or '<column>_growth[_<duration>]' where
+// column is a numeric column, for example 'number_of_time_series_change_7d'.
+func trendColumn(name string) (Column, bool) {
+	for _, base := range columns {
+		if !base.Numeric {
+			continue
+		}
+		for _, kind := range []string{trendChange, trendGrowth} {
+			prefix := base.Name + kind
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			t := &trend{base: base, percent: kind == trendGrowth}
+			if rest := name[len(prefix):]; rest != "" {
+				if !strings.HasPrefix(rest, "_") {
+					continue
+				}
+				d, err := model.ParseDuration(rest[1:])
+				if err != nil || d == 0 {
+					continue
+				}
+				t.since = d
+			}
+			return newTrendColumn(name, t), true
+		}
+	}
+	return Column{}, false
+}
+
+func newTrendColumn(name string, t *trend) Column {
+	since, shortSince := "last run", ""
+	if t.since != 0 {
+		since, shortSince = t.since.String()+" ago", t.since.String()
+	}
+	c := Column{
+		Name:        name,
+		Header:      t.base.Header + " change vs " + since,
+		ShortHeader: t.base.ShortHeader + " Δ" + shortSince,
+		Numeric:     true,
+		value:       func(ps *PromSummary) string { return ps.Trends[name] },
+		humanize: func(v string) string {
+			if strings.HasPrefix(v, "-") || v == "0" {
+				return humanizeNumber(v)
+			}
+			return "+" + humanizeNumber(v)
+		},
+		trend: t,
+	}
+	if t.percent {
+		c.Header = t.base.Header + " growth vs " + since
+		c.ShortHeader = t.base.ShortHeader + " %" + shortSince
+		c.humanize = func(v string) string {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return v
+			}
+			return fmt.Sprintf("%+.1f%%", f)
+		}
+	}
+	return c
+}
+
+// trendColumns returns the trend columns used by the outputs, either as
+// a column or as a threshold, without duplicates.
+func trendColumns(outputs []*output) []Column {
+	var trends []Column
+	seen := make(map[string]bool)
+	add := func(c Column) {
+		if c.trend != nil && !seen[c.Name] {
+			seen[c.Name] = true
+			trends = append(trends, c)
+		}
+	}
+	for _, out := range outputs {
+		for _, c := range out.columns {
+			add(c)
+		}
+		for name := range out.cfg.Thresholds {
+			if c, err := lookupColumn(name); err == nil {
+				add(c)
+			}
+		}
+	}
+	return trends
+}
+
+// trendBaselines are the previous summaries the trend columns are compared
+// to, by how long ago they were collected and by instance name.
+type trendBaselines map[model.Duration]map[string]*PromSummary
+
+// loadTrendBaselines reads the baselines of the trend columns from the
+// history, for a run started at the given time.
+func loadTrendBaselines(history *historyStore, trends []Column, now time.Time) (trendBaselines, error) {
+	baselines := make(trendBaselines)
+	for _, c := range trends {
+		if _, ok := baselines[c.trend.since]; ok {
+			continue
+		}
+		last, err := history.Last(now.Add(-time.Duration(c.trend.since)))
+		if err != nil {
+			return nil, err
+		}
+		baselines[c.trend.since] = last
+	}
+	return baselines, nil
+}
+
+// setTrends computes the trend columns of the summary. They are left empty
+// when there is no baseline, or when a value is not a number, for example
+// when the instance is or was NotOK. Growth is left empty if the old value is 0.
+func setTrends(ps *PromSummary, trends []Column, baselines trendBaselines) {
+	for _, c := range trends {
+		old := baselines[c.trend.since][ps.Name]
+		if old == nil {
+			continue
+		}
+		o, err := strconv.ParseFloat(c.trend.base.Value(old), 64)
+		if err != nil {
+			continue
+		}
+		n, err := strconv.ParseFloat(c.trend.base.Value(ps), 64)
+		if err != nil {
+			continue
+		}
+		if ps.Trends == nil {
+			ps.Trends = make(map[string]string)
+		}
+		switch {
+		case !c.trend.percent:
+			ps.Trends[c.Name] = strconv.FormatFloat(n-o, 'f', -1, 64)
+		case o != 0:
+			ps.Trends[c.Name] = trimDecimals((n-o)/o*100, 2)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestTrendColumn(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		ok          bool
+		percent     bool
+		since       model.Duration
+		header      string
+		shortHeader string
+	}{
+		{
+			name:        "number_of_time_series_change",
+			ok:          true,
+			header:      "number of time series change vs last run",
+			shortHeader: "series Δ",
+		},
+		{
+			name:        "number_of_time_series_growth_7d",
+			ok:          true,
+			percent:     true,
+			since:       model.Duration(7 * 24 * time.Hour),
+			header:      "number of time series growth vs 1w ago",
+			shortHeader: "series %1w",
+		},
+		{
+			name:        "number_of_chunks_change_1h",
+			ok:          true,
+			since:       model.Duration(time.Hour),
+			header:      "number of chunks change vs 1h ago",
+			shortHeader: "chunks Δ1h",
+		},
+		{name: "version_change"},
+		{name: "number_of_time_series_change_0s"},
+		{name: "number_of_time_series_change_abc"},
+		{name: "number_of_time_series_change7d"},
+		{name: "number_of_time_series_delta"},
+	} {
+		c, ok := trendColumn(tc.name)
+		if ok != tc.ok {
+			t.Errorf("%s: expected ok=%t, got %t", tc.name, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if c.Name != tc.name || c.trend.percent != tc.percent || c.trend.since != tc.since || !c.Numeric {
+			t.Errorf("%s: unexpected trend %+v", tc.name, c.trend)
+		}
+		if c.Header != tc.header || c.ShortHeader != tc.shortHeader {
+			t.Errorf("%s: unexpected headers %q and %q", tc.name, c.Header, c.ShortHeader)
+		}
+	}
+}
+
+func TestTrendHumanize(t *testing.T) {
+	change, _ := trendColumn("number_of_time_series_change")
+	growth, _ := trendColumn("number_of_time_series_growth")
+	for _, tc := range []struct {
+		c        Column
+		in       string
+		expected string
+	}{
+		{change, "1500", "+1.5k"},
+		{change, "-1500", "-1.5k"},
+		{change, "0", "0"},
+		{growth, "12.345", "+12.3%"},
+		{growth, "-5", "-5.0%"},
+		{growth, "", ""},
+	} {
+		if got := tc.c.Humanize(tc.in); got != tc.expected {
+			t.Errorf("%s %q: expected %q, got %q", tc.c.Name, tc.in, tc.expected, got)
+		}
+	}
+}
+
+func TestSetTrends(t *testing.T) {
+	var trends []Column
+	for _, name := range []string{
+		"number_of_time_series_change", "number_of_time_series_growth", "number_of_chunks_change_1d",
+	} {
+		c, ok := trendColumn(name)
+		if !ok {
+			t.Fatalf("unknown trend column %s", name)
+		}
+		trends = append(trends, c)
+	}
+	baselines := trendBaselines{
+		0: {
+			"p1": {Name: "p1", NumOfTimeSeries: "1000"},
+			"p2": {Name: "p2", NumOfTimeSeries: "0"},
+			"p3": {Name: "p3", Status: PromStatusNotOK},
+		},
+		model.Duration(24 * time.Hour): {
+			"p1": {Name: "p1", NumOfChunks: "500"},
+		},
+	}
+	for _, tc := range []struct {
+		ps       *PromSummary
+		expected map[string]string
+	}{
+		{
+			ps: &PromSummary{Name: "p1", NumOfTimeSeries: "1500", NumOfChunks: "400"},
+			expected: map[string]string{
+				"number_of_time_series_change": "500",
+				"number_of_time_series_growth": "50",
+				"number_of_chunks_change_1d":   "-100",
+			},
+		},
+		{
+			// There is no growth from 0.
+			ps:       &PromSummary{Name: "p2", NumOfTimeSeries: "10"},
+			expected: map[string]string{"number_of_time_series_change": "10"},
+		},
+		{
+			// The instance was NotOK.
+			ps:       &PromSummary{Name: "p3", NumOfTimeSeries: "10"},
+			expected: nil,
+		},
+		{
+			// The instance is new.
+			ps:       &PromSummary{Name: "p4", NumOfTimeSeries: "10"},
+			expected: nil,
+		},
+	} {
+		setTrends(tc.ps, trends, baselines)
+		if len(tc.ps.Trends) != len(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.ps.Name, tc.expected, tc.ps.Trends)
+			continue
+		}
+		for k, v := range tc.expected {
+			if tc.ps.Trends[k] != v {
+				t.Errorf("%s: expected %v, got %v", tc.ps.Name, tc.expected, tc.ps.Trends)
+				break
+			}
+		}
+	}
+}
+
+func TestLoadTrendBaselines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prom-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	history, err := openHistory(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, run := range []struct {
+		ago    time.Duration
+		series string
+	}{
+		{8 * 24 * time.Hour, "100"},
+		{6 * 24 * time.Hour, "200"},
+		{time.Hour, "300"},
+	} {
+		if err := history.Append([]*PromSummary{
+			{Name: "p1", NumOfTimeSeries: run.series, CollectedAt: now.Add(-run.ago)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var trends []Column
+	for _, name := range []string{
+		"number_of_time_series_change", "number_of_time_series_growth", "number_of_time_series_change_7d",
+	} {
+		c, _ := trendColumn(name)
+		trends = append(trends, c)
+	}
+	baselines, err := loadTrendBaselines(history, trends, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baselines) != 2 {
+		t.Fatalf("expected 2 baselines, got %d", len(baselines))
+	}
+	if got := baselines[0]["p1"]; got == nil || got.NumOfTimeSeries != "300" {
+		t.Errorf("unexpected last run baseline %+v", got)
+	}
+	if got := baselines[model.Duration(7*24*time.Hour)]["p1"]; got == nil || got.NumOfTimeSeries != "100" {
+		t.Errorf("unexpected 7d baseline %+v", got)
+	}
+}
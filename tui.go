@@ -0,0 +1,457 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// Terminal escape sequences of the TUI.
+const (
+	enterAltScreen = "\033[?1049h\033[?25l"
+	exitAltScreen  = "\033[?25h\033[?1049l"
+	reverseVideo   = 7
+)
+
+// Keys of the TUI, the arrows are decoded from their escape sequences,
+// the decoded keys are negative not to collide with the typed runes.
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEscape
+)
+
+const (
+	keyEnter     = '\r'
+	keyBackspace = 127
+	keyCtrlC     = 3
+)
+
+// tuiColumns are the columns of the instance list.
+var tuiColumns, _ = parseColumns([]string{"name", "status", "version", "number_of_active_targets",
+	"number_of_dropped_targets", "number_of_time_series", "number_of_ingested_samples_per_seconds"})
+
+// tui is an interactive terminal UI to browse the summaries: the instance
+// list can be filtered, and every instance has a detail view.
+type tui struct {
+	promCfgs map[string]PrometheusConfig
+	timeout  time.Duration
+	in       *bufio.Reader
+	out      io.Writer
+
+	// results are the summaries sorted by name.
+	results []*PromSummary
+	cursor  int
+	filter  string
+	// filtering is set while the filter is typed.
+	filtering bool
+	// detail is the instance of the detail view, nil in the list view.
+	detail *PromSummary
+	scroll int
+	// message is shown in the status line.
+	message string
+}
+
+// runTUI runs the TUI until it is quit, the terminal is restored on exit.
+func runTUI(promCfgs map[string]PrometheusConfig, timeout time.Duration) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("the tui needs a terminal")
+	}
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+	fmt.Fprint(os.Stdout, enterAltScreen)
+	defer fmt.Fprint(os.Stdout, exitAltScreen)
+
+	t := &tui{promCfgs: promCfgs, timeout: timeout, in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	t.refreshAll()
+	for {
+		t.render()
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		if !t.handle(key) {
+			return nil
+		}
+	}
+}
+
+// readKey reads a key press. A lone escape is the escape key, escape
+// sequences are decoded to the arrow and page keys, the others ignored.
+func (t *tui) readKey() (rune, error) {
+	r, _, err := t.in.ReadRune()
+	if err != nil || r != '\033' {
+		return r, err
+	}
+	if t.in.Buffered() == 0 {
+		return keyEscape, nil
+	}
+	if b, _ := t.in.ReadByte(); b != '[' && b != 'O' {
+		return keyEscape, nil
+	}
+	var seq []byte
+	for t.in.Buffered() > 0 {
+		b, _ := t.in.ReadByte()
+		seq = append(seq, b)
+		if b >= 'A' && b <= 'Z' || b == '~' {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "5~":
+		return keyPageUp, nil
+	case "6~":
+		return keyPageDown, nil
+	}
+	return 0, nil
+}
+
+// handle applies a key press, it returns false to quit.
+func (t *tui) handle(key rune) bool {
+	if key == keyCtrlC {
+		return false
+	}
+	t.message = ""
+	switch {
+	case t.filtering:
+		switch key {
+		case keyEnter, keyEscape:
+			t.filtering = false
+		case keyBackspace, '\b':
+			if r := []rune(t.filter); len(r) > 0 {
+				t.filter = string(r[:len(r)-1])
+			}
+		default:
+			if key >= ' ' && key != keyBackspace {
+				t.filter += string(key)
+			}
+		}
+		t.cursor = 0
+	case t.detail != nil:
+		switch key {
+		case keyUp, 'k':
+			t.scroll--
+		case keyDown, 'j':
+			t.scroll++
+		case keyPageUp:
+			t.scroll -= t.height() - 2
+		case keyPageDown:
+			t.scroll += t.height() - 2
+		case 'r':
+			t.detail = t.refresh(t.detail.Name)
+		case keyEscape, keyBackspace, 'q':
+			t.detail = nil
+		}
+	default:
+		visible := t.visible()
+		switch key {
+		case keyUp, 'k':
+			t.cursor--
+		case keyDown, 'j':
+			t.cursor++
+		case keyPageUp:
+			t.cursor -= t.height() - 4
+		case keyPageDown:
+			t.cursor += t.height() - 4
+		case '/':
+			t.filtering = true
+		case keyEscape:
+			t.filter = ""
+		case keyEnter:
+			if t.cursor < len(visible) {
+				t.detail, t.scroll = visible[t.cursor], 0
+			}
+		case 'r':
+			if t.cursor < len(visible) {
+				t.refresh(visible[t.cursor].Name)
+			}
+		case 'R':
+			t.refreshAll()
+		case 'q':
+			return false
+		}
+	}
+	return true
+}
+
+// refreshAll collects the summaries of all the instances.
+func (t *tui) refreshAll() {
+	t.status("Collecting the summaries...")
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	t.results = collectAll(ctx, t.promCfgs, true, nil)
+	sort.Slice(t.results, func(i, j int) bool { return t.results[i].Name < t.results[j].Name })
+	t.message = fmt.Sprintf("Collected at %s", time.Now().Format("15:04:05"))
+}
+
+// refresh collects the summary of an instance and returns it.
+func (t *tui) refresh(name string) *PromSummary {
+	t.status(fmt.Sprintf("Collecting the summary of %s...", name))
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	ps := collect(ctx, name, t.promCfgs[name], true)
+	for i, r := range t.results {
+		if r.Name == name {
+			t.results[i] = ps
+		}
+	}
+	t.message = fmt.Sprintf("%s collected at %s", name, time.Now().Format("15:04:05"))
+	return ps
+}
+
+// status shows a message while the UI waits for a collection.
+func (t *tui) status(message string) {
+	t.message = message
+	t.render()
+}
+
+// visible returns the instances matching the filter, case-insensitively
+// on the name, address, status, version and labels.
+func (t *tui) visible() []*PromSummary {
+	if t.filter == "" {
+		return t.results
+	}
+	filter := strings.ToLower(t.filter)
+	var visible []*PromSummary
+	for _, ps := range t.results {
+		fields := []string{ps.Name, ps.Address, ps.Status.String(), ps.Version}
+		for k, v := range ps.Labels {
+			fields = append(fields, k+"="+v)
+		}
+		if strings.Contains(strings.ToLower(strings.Join(fields, " ")), filter) {
+			visible = append(visible, ps)
+		}
+	}
+	return visible
+}
+
+func (t *tui) height() int {
+	if _, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && height > 4 {
+		return height
+	}
+	return 24
+}
+
+// render redraws the screen: a title line, the list or the detail view,
+// and the status line.
+func (t *tui) render() {
+	height := t.height()
+	var lines []string
+	if t.detail != nil {
+		lines = t.detailLines(height - 2)
+	} else {
+		lines = t.listLines(height - 2)
+	}
+
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	title := "prom-summary"
+	if t.detail != nil {
+		title += " / " + t.detail.Name
+	}
+	b.WriteString(colorize(title, tablewriter.Colors{tablewriter.Bold}) + "\r\n")
+	for _, line := range lines {
+		b.WriteString(line + "\r\n")
+	}
+	for i := len(lines); i < height-2; i++ {
+		b.WriteString("\r\n")
+	}
+	b.WriteString(t.statusLine())
+	io.WriteString(t.out, b.String())
+}
+
+func (t *tui) statusLine() string {
+	var help string
+	switch {
+	case t.filtering:
+		return "/" + t.filter + "_"
+	case t.detail != nil:
+		help = "↑/↓ scroll  r refresh  esc back  ctrl-c quit"
+	default:
+		help = "↑/↓ move  enter details  / filter  r refresh  R refresh all  q quit"
+		if t.filter != "" {
+			help = "filter: " + t.filter + " (esc to clear)  " + help
+		}
+	}
+	if t.message != "" {
+		help = t.message + "  |  " + help
+	}
+	return colorize(help, tablewriter.Colors{reverseVideo})
+}
+
+// listLines renders the instance list, scrolled to keep the selected
+// instance visible.
+func (t *tui) listLines(height int) []string {
+	visible := t.visible()
+	if t.cursor >= len(visible) {
+		t.cursor = len(visible) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	if len(visible) == 0 {
+		return []string{"No instance matches the filter."}
+	}
+
+	var b strings.Builder
+	table := newTable(&b, "table-compact")
+	table.SetHeader(shortHeaders(tuiColumns))
+	for _, ps := range visible {
+		table.Append(row(tuiColumns, ps, true))
+	}
+	table.Render()
+	rendered := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+
+	// The first line is the header, the rows follow in order.
+	rows := rendered[1:]
+	first := 0
+	if size := height - 1; t.cursor >= size {
+		first = t.cursor - size + 1
+	}
+	lines := []string{colorize(rendered[0], tablewriter.Colors{tablewriter.Bold})}
+	for i := first; i < len(rows) && len(lines) < height; i++ {
+		var colors tablewriter.Colors
+		if visible[i].Status != PromStatusOK {
+			colors = append(colors, tablewriter.FgRedColor)
+		}
+		if i == t.cursor {
+			colors = append(colors, reverseVideo)
+		}
+		lines = append(lines, colorize(rows[i], colors))
+	}
+	return lines
+}
+
+// detailLines renders the detail sections of the instance, from the
+// scroll position.
+func (t *tui) detailLines(height int) []string {
+	ps := t.detail
+	var lines []string
+	section := func(title string) {
+		lines = append(lines, "", colorize(title, tablewriter.Colors{tablewriter.Bold}))
+	}
+	table := func(header []string, rows [][]string) {
+		if len(rows) == 0 {
+			lines = append(lines, "  none")
+			return
+		}
+		var b strings.Builder
+		tw := newTable(&b, "table-compact")
+		tw.SetHeader(header)
+		tw.AppendBulk(rows)
+		tw.Render()
+		for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+			lines = append(lines, "  "+line)
+		}
+	}
+
+	width := 0
+	for _, c := range columns {
+		if len(c.Header) > width {
+			width = len(c.Header)
+		}
+	}
+	cells := row(columns, ps, true)
+	for i, c := range columns {
+		if cells[i] == "" {
+			continue
+		}
+		v := cells[i]
+		if c.Name == "status" && ps.Status != PromStatusOK {
+			v = colorize(v, tablewriter.Colors{tablewriter.FgRedColor})
+		}
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, c.Header, v))
+	}
+	if len(ps.Labels) > 0 {
+		var labels []string
+		for k, v := range ps.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, "labels", strings.Join(labels, " ")))
+	}
+
+	if d := ps.Details; d != nil {
+		section("Targets by job")
+		var rows [][]string
+		for _, j := range d.TargetsByJob {
+			rows = append(rows, []string{j.Job, strconv.Itoa(j.Up), strconv.Itoa(j.Down), strconv.Itoa(j.Unknown)})
+		}
+		table([]string{"job", "up", "down", "unknown"}, rows)
+
+		section("Rule groups")
+		rows = nil
+		for _, g := range d.RuleGroups {
+			rows = append(rows, []string{g.Name, g.File, strconv.Itoa(g.Rules), strconv.Itoa(g.Unhealthy), strconv.Itoa(g.Firing)})
+		}
+		table([]string{"group", "file", "rules", "unhealthy", "firing"}, rows)
+
+		section("Top metrics")
+		rows = nil
+		for _, m := range d.TopMetrics {
+			rows = append(rows, []string{m.Name, humanizeNumber(strconv.FormatUint(m.Series, 10))})
+		}
+		table([]string{"metric", "series"}, rows)
+
+		if r := d.Runtime; r != nil {
+			section("Runtime")
+			rows = [][]string{
+				{"start time", formatTime(r.StartTime)},
+				{"config reload success", strconv.FormatBool(r.ReloadConfigSuccess)},
+				{"last config time", formatTime(r.LastConfigTime)},
+				{"corruption count", strconv.Itoa(r.CorruptionCount)},
+				{"goroutines", strconv.Itoa(r.GoroutineCount)},
+				{"GOMAXPROCS", strconv.Itoa(r.GOMAXPROCS)},
+				{"GOGC", r.GOGC},
+			}
+			for _, r := range rows {
+				lines = append(lines, fmt.Sprintf("  %-24s %s", r[0], r[1]))
+			}
+		}
+	}
+
+	if last := len(lines) - height; t.scroll > last {
+		t.scroll = last
+	}
+	if t.scroll < 0 {
+		t.scroll = 0
+	}
+	lines = lines[t.scroll:]
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
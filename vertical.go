@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// minVerticalValueWidth is the minimum width of the values of the vertical
+// table, below it the abbreviated headers are used, and narrower terminals
+// are not taken into account.
+const minVerticalValueWidth = 30
+
+// writeVertical writes one block of 'key: value' lines per Prometheus
+// instance. If width is not 0, the long values are wrapped to fit it, and
+// the abbreviated headers are used as keys if the full ones are too wide.
+// colors are the colours of the cells, nil if the table is not colourised.
+func writeVertical(w io.Writer, columns []Column, rows [][]string,
+	colors [][]tablewriter.Colors, width int) error {
+	keys := headers(columns)
+	keyWidth, valueWidth := verticalWidths(keys, width)
+	if width > 0 && valueWidth < minVerticalValueWidth {
+		keys = shortHeaders(columns)
+		keyWidth, valueWidth = verticalWidths(keys, width)
+	}
+	if width > 0 && valueWidth < minVerticalValueWidth {
+		valueWidth = minVerticalValueWidth
+	}
+
+	for i, r := range rows {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		for j := range columns {
+			lines := wrapText(r[j], valueWidth)
+			for k, line := range lines {
+				key := keys[j] + ":"
+				if k > 0 {
+					key = ""
+				}
+				if colors != nil {
+					line = colorize(line, colors[i][j])
+				}
+				out := strings.TrimRight(fmt.Sprintf("%-*s %s", keyWidth+1, key, line), " ")
+				if _, err := fmt.Fprintln(w, out); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verticalWidths returns the width of the keys column and the width left
+// for the values, 0 if the terminal width is unknown.
+func verticalWidths(keys []string, width int) (int, int) {
+	keyWidth := 0
+	for _, k := range keys {
+		if n := utf8.RuneCountInString(k); n > keyWidth {
+			keyWidth = n
+		}
+	}
+	if width <= 0 {
+		return keyWidth, 0
+	}
+	return keyWidth, width - keyWidth - 2
+}
+
+// wrapText splits s in lines of at most width runes, on spaces when
+// possible. s is returned as a single line if width is 0.
+func wrapText(s string, width int) []string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return []string{s}
+	}
+	var (
+		lines []string
+		line  []rune
+	)
+	for _, word := range strings.Fields(s) {
+		runes := []rune(word)
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+		for len(line) > width {
+			lines = append(lines, string(line[:width]))
+			line = line[width:]
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// changedMarker suffixes the changed cells when the table is not colourised.
+const changedMarker = "*"
+
+// watcher redraws the table of the summaries on every refresh, the cells
+// which have changed since the previous refresh are highlighted.
+type watcher struct {
+	out      *output
+	interval time.Duration
+	color    bool
+	// clear tells whether the screen is cleared before every redraw, it is
+	// only done for a terminal.
+	clear bool
+	// previous are the raw cells of the previous refresh, by instance name.
+	previous map[string][]string
+	// lastSuccess is the time of the last successful collection, by instance name.
+	lastSuccess map[string]time.Time
+}
+
+func newWatcher(out *output, interval time.Duration, colorMode string) *watcher {
+	return &watcher{
+		out:         out,
+		interval:    interval,
+		color:       useColor(colorMode, ""),
+		clear:       isTerminal(os.Stdout),
+		previous:    make(map[string][]string),
+		lastSuccess: make(map[string]time.Time),
+	}
+}
+
+// run collects the summaries and redraws the table every interval,
+// until the context is cancelled.
+func (wt *watcher) run(ctx context.Context, promCfgs map[string]PrometheusConfig) error {
+	ticker := time.NewTicker(wt.interval)
+	defer ticker.Stop()
+	for {
+		collectCtx, cancel := context.WithTimeout(ctx, wt.interval)
+		results := collectAll(collectCtx, promCfgs, false, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := wt.render(os.Stdout, results, time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// render writes the table of the results, with the time of the last
+// successful collection of every instance.
+func (wt *watcher) render(w io.Writer, results []*PromSummary, now time.Time) error {
+	o := wt.out
+	results = o.sorted(results)
+	humanize := o.cfg.Humanize
+	format := "table"
+	if o.cfg.Format == "table-compact" {
+		format = o.cfg.Format
+	}
+
+	var b strings.Builder
+	if wt.clear {
+		b.WriteString(clearScreen)
+	}
+	fmt.Fprintf(&b, "Every %s: prom-summary\t%s\n\n", wt.interval, now.Format(time.RFC1123))
+
+	table := newTable(&b, format)
+	if format == "table-compact" {
+		table.SetHeader(append(shortHeaders(o.columns), "last success"))
+	} else {
+		table.SetHeader(append(headers(o.columns), "last success"))
+	}
+	current := make(map[string][]string, len(results))
+	for _, ps := range results {
+		if ps.Status == PromStatusOK {
+			wt.lastSuccess[ps.Name] = ps.CollectedAt
+		}
+		raw := row(o.columns, ps, false)
+		current[ps.Name] = raw
+		cells := row(o.columns, ps, humanize)
+		colors := make([]tablewriter.Colors, len(o.columns)+1)
+		if wt.color {
+			copy(colors, rowColors(o.columns, o.cfg.Thresholds, ps))
+		}
+		for i := range wt.changed(ps.Name, raw) {
+			if wt.color {
+				colors[i] = append(colors[i], tablewriter.Bold, tablewriter.BgBlueColor)
+			} else {
+				cells[i] += changedMarker
+			}
+		}
+		last := "never"
+		if t, ok := wt.lastSuccess[ps.Name]; ok {
+			last = t.UTC().Format(time.RFC3339)
+		}
+		cells = append(cells, last)
+		if wt.color {
+			table.Rich(cells, colors)
+			continue
+		}
+		table.Append(cells)
+	}
+	if o.cfg.Totals {
+		table.Append(append(totalsRow(o.columns, computeTotals(results), humanize), ""))
+	}
+	table.Render()
+	wt.previous = current
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// changed returns the indexes of the cells which have changed since the
+// previous refresh. Nothing has changed for a new instance, and the
+// collection time always changes, it is ignored.
+func (wt *watcher) changed(name string, raw []string) map[int]bool {
+	changed := make(map[int]bool)
+	previous, ok := wt.previous[name]
+	if !ok {
+		return changed
+	}
+	for i, c := range wt.out.columns {
+		if c.Name == "collected_at" || i >= len(previous) {
+			continue
+		}
+		if raw[i] != previous[i] {
+			changed[i] = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWatcher(t *testing.T, color bool) *watcher {
+	t.Helper()
+	outCfg := DefaultOutputConfig
+	outCfg.Format = "table"
+	outCfg.Columns = []string{"name", "status", "version", "number_of_time_series", "collected_at"}
+	out, err := newOutput(outCfg, ColorNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := newWatcher(out, time.Minute, ColorNever)
+	wt.color, wt.clear = color, false
+	return wt
+}
+
+// watchRows returns the table rows of a rendered refresh by instance name,
+// as their trimmed cells.
+func watchRows(out string) map[string][]string {
+	rows := make(map[string][]string)
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		var cells []string
+		for _, c := range strings.Split(strings.Trim(line, "|"), "|") {
+			cells = append(cells, strings.TrimSpace(c))
+		}
+		rows[cells[0]] = cells
+	}
+	return rows
+}
+
+func TestWatcherRender(t *testing.T) {
+	wt := newTestWatcher(t, false)
+	first := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	var b bytes.Buffer
+	if err := wt.render(&b, []*PromSummary{
+		{Name: "p1", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1000", CollectedAt: first},
+		{Name: "p2", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "500", CollectedAt: first},
+		{Name: "p3", Status: PromStatusNotOK, Error: "connection refused", CollectedAt: first},
+	}, first); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Every 1m0s: prom-summary") || strings.Contains(b.String(), clearScreen) {
+		t.Errorf("unexpected title:\n%s", b.String())
+	}
+	rows := watchRows(b.String())
+	// Nothing has changed on the first refresh.
+	if strings.Contains(b.String(), changedMarker) {
+		t.Errorf("unexpected changed cells on the first refresh:\n%s", b.String())
+	}
+	for name, last := range map[string]string{"p1": "2021-03-01T12:00:00Z", "p3": "never"} {
+		if got := rows[name][len(rows[name])-1]; got != last {
+			t.Errorf("%s: expected the last success %q, got %q", name, last, got)
+		}
+	}
+
+	b.Reset()
+	if err := wt.render(&b, []*PromSummary{
+		// The series have changed, the collection time is ignored.
+		{Name: "p1", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: "1200", CollectedAt: second},
+		// The instance is down.
+		{Name: "p2", Status: PromStatusNotOK, Error: "timeout", CollectedAt: second},
+		{Name: "p3", Status: PromStatusNotOK, Error: "connection refused", CollectedAt: second},
+		// The instance is new.
+		{Name: "p4", Status: PromStatusOK, Version: "2.26.0", NumOfTimeSeries: "10", CollectedAt: second},
+	}, second); err != nil {
+		t.Fatal(err)
+	}
+	rows = watchRows(b.String())
+	for _, tc := range []struct {
+		name     string
+		expected []string
+	}{
+		{"p1", []string{"p1", "OK", "2.25.0", "1200*", "2021-03-01T12:01:00Z", "2021-03-01T12:01:00Z"}},
+		{"p2", []string{"p2", "NotOK*", "*", "*", "2021-03-01T12:01:00Z", "2021-03-01T12:00:00Z"}},
+		{"p3", []string{"p3", "NotOK", "", "", "2021-03-01T12:01:00Z", "never"}},
+		{"p4", []string{"p4", "OK", "2.26.0", "10", "2021-03-01T12:01:00Z", "2021-03-01T12:01:00Z"}},
+	} {
+		if got := rows[tc.name]; strings.Join(got, "|") != strings.Join(tc.expected, "|") {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestWatcherRenderColor(t *testing.T) {
+	wt := newTestWatcher(t, true)
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, series := range []string{"1000", "1200"} {
+		var b bytes.Buffer
+		if err := wt.render(&b, []*PromSummary{
+			{Name: "p1", Status: PromStatusOK, Version: "2.25.0", NumOfTimeSeries: series, CollectedAt: now},
+		}, now); err != nil {
+			t.Fatal(err)
+		}
+		// The changed cells are highlighted, bold on blue, instead of
+		// being marked.
+		highlighted := strings.Count(b.String(), "\033[1;44m")
+		if series == "1200" && (highlighted != 1 || strings.Contains(b.String(), "1200"+changedMarker)) {
+			t.Errorf("expected the changed cell to be highlighted:\n%q", b.String())
+		}
+		if series == "1000" && highlighted != 0 {
+			t.Errorf("unexpected highlighted cell on the first refresh:\n%q", b.String())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// validateWebhook checks the webhook configuration.
+func validateWebhook(cfg *WebhookConfig) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid webhook url %q", cfg.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("invalid webhook url %q, the scheme must be http or https", cfg.URL)
+	}
+	if cfg.MaxRetries < 0 {
+		return errors.Errorf("invalid webhook max_retries %d", cfg.MaxRetries)
+	}
+	return nil
+}
+
+// webhookDestination returns the webhook URL without its credentials
+// and query, to be printed.
+func webhookDestination(cfg *WebhookConfig) string {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// sendWebhook sends the report to the webhook. It is retried on network
+// errors and on 5xx and 429 responses, the other responses but 2xx fail.
+func sendWebhook(cfg *WebhookConfig, contentType string, body []byte) error {
+	client := &http.Client{Timeout: time.Duration(cfg.Timeout)}
+	wait := time.Duration(cfg.RetryInterval)
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = postWebhook(client, cfg, contentType, body)
+		if err == nil || !retry || attempt >= cfg.MaxRetries {
+			break
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+	return err
+}
+
+// postWebhook makes a single attempt, it tells whether it can be retried.
+func postWebhook(client *http.Client, cfg *WebhookConfig, contentType string, body []byte) (bool, error) {
+	req, err := http.NewRequest(cfg.Method, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "prom-summary")
+	if cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(cfg.BasicAuth.Username, cfg.BasicAuth.Password)
+	}
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = errors.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
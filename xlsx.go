@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Cell styles, they are the indexes of cellXfs in xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleOK
+	xlsxStyleNotOK
+)
+
+const (
+	xlsxMinColumnWidth = 8
+	xlsxMaxColumnWidth = 80
+)
+
+// xlsxCell is a single worksheet cell. Numeric cells are written as numbers
+// so that spreadsheets can compute on them, the others as inline strings.
+type xlsxCell struct {
+	Value   string
+	Numeric bool
+	Style   int
+}
+
+// xlsxSheet is a worksheet with a frozen header row.
+type xlsxSheet struct {
+	Name   string
+	Header []string
+	Rows   [][]xlsxCell
+}
+
+// xlsxPart is a file of the xlsx zip package.
+type xlsxPart struct {
+	name    string
+	content []byte
+}
+
+// writeXLSX writes the results as an Excel workbook. The first sheet is the
+// summary, one row per Prometheus instance, the other sheets are the detail
+// sections which have been collected.
+func writeXLSX(w io.Writer, columns []Column, results []*PromSummary) error {
+	summary := xlsxSheet{Name: "Summary", Header: headers(columns)}
+	targets := xlsxSheet{
+		Name:   "Targets",
+		Header: []string{"name", "job", "up", "down", "unknown"},
+	}
+	metrics := xlsxSheet{
+		Name:   "Top Metrics",
+		Header: []string{"name", "metric", "number of time series"},
+	}
+	for _, record := range results {
+		row := make([]xlsxCell, 0, len(columns))
+		for _, c := range columns {
+			cell := xlsxCell{Value: c.Value(record)}
+			if c.Name == "status" {
+				cell.Style = xlsxStyleOK
+				if record.Status != PromStatusOK {
+					cell.Style = xlsxStyleNotOK
+				}
+			}
+			if c.Numeric {
+				cell.Numeric = isFinite(cell.Value)
+			}
+			row = append(row, cell)
+		}
+		summary.Rows = append(summary.Rows, row)
+
+		if record.Details == nil {
+			continue
+		}
+		for _, job := range record.Details.TargetsByJob {
+			targets.Rows = append(targets.Rows, []xlsxCell{
+				{Value: record.Name},
+				{Value: job.Job},
+				{Value: strconv.Itoa(job.Up), Numeric: true},
+				{Value: strconv.Itoa(job.Down), Numeric: true},
+				{Value: strconv.Itoa(job.Unknown), Numeric: true},
+			})
+		}
+		for _, metric := range record.Details.TopMetrics {
+			metrics.Rows = append(metrics.Rows, []xlsxCell{
+				{Value: record.Name},
+				{Value: metric.Name},
+				{Value: strconv.FormatUint(metric.Series, 10), Numeric: true},
+			})
+		}
+	}
+
+	sheets := []xlsxSheet{summary}
+	for _, sheet := range []xlsxSheet{targets, metrics} {
+		if len(sheet.Rows) > 0 {
+			sheets = append(sheets, sheet)
+		}
+	}
+	return writeWorkbook(w, sheets)
+}
+
+// isFinite tells whether s is a finite number, SpreadsheetML has no NaN
+// nor infinite numbers, they are written as strings.
+func isFinite(s string) bool {
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// writeWorkbook writes the sheets as a SpreadsheetML (xlsx) package.
+func writeWorkbook(w io.Writer, sheets []xlsxSheet) error {
+	var (
+		contentTypes bytes.Buffer
+		workbook     bytes.Buffer
+		workbookRels bytes.Buffer
+	)
+	contentTypes.WriteString(xml.Header)
+	contentTypes.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header)
+	workbookRels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, sheet := range sheets {
+		id := i + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, id)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.Name), id, id)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, id, id)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" `+
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" `+
+		`Target="styles.xml"/></Relationships>`, len(sheets)+1)
+
+	parts := []xlsxPart{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(xml.Header + xlsxRootRels)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+		{"xl/styles.xml", []byte(xml.Header + xlsxStyles)},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.marshal()})
+	}
+
+	zw := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// marshal renders the worksheet XML, the header row is frozen and the
+// column widths are computed from the longest value of each column.
+func (s xlsxSheet) marshal() []byte {
+	widths := make([]int, len(s.Header))
+	for i, h := range s.Header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range s.Rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell.Value); i < len(widths) && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews>`)
+	b.WriteString(`<cols>`)
+	for i, width := range widths {
+		width += 2
+		if width < xlsxMinColumnWidth {
+			width = xlsxMinColumnWidth
+		}
+		if width > xlsxMaxColumnWidth {
+			width = xlsxMaxColumnWidth
+		}
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+	}
+	b.WriteString(`</cols><sheetData>`)
+	header := make([]xlsxCell, len(s.Header))
+	for i, h := range s.Header {
+		header[i] = xlsxCell{Value: h, Style: xlsxStyleHeader}
+	}
+	for r, row := range append([][]xlsxCell{header}, s.Rows...) {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := xlsxColumnName(c) + strconv.Itoa(r+1)
+			if cell.Numeric {
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, cell.Style, cell.Value)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`,
+				ref, cell.Style, xmlEscape(cell.Value))
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+// xlsxColumnName converts a zero-based column index to its letters: A, B, ..., AA.
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xlsxRootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" ` +
+	`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+	`Target="xl/workbook.xml"/></Relationships>`
+
+// xlsxStyles defines the cell styles, in the same order as xlsxStyle* constants:
+// default, bold header on grey, OK on green, NotOK on red.
+const xlsxStyles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2">` +
+	`<font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+	`</fonts>` +
+	`<fills count="5">` +
+	`<fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9D9D9"/><bgColor indexed="64"/></patternFill></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFC6EFCE"/><bgColor indexed="64"/></patternFill></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFFFC7CE"/><bgColor indexed="64"/></patternFill></fill>` +
+	`</fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="0" fontId="0" fillId="3" borderId="0" xfId="0" applyFill="1"/>` +
+	`<xf numFmtId="0" fontId="0" fillId="4" borderId="0" xfId="0" applyFill="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Kien Nguyen-Tuan <kiennt2609@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestIsFinite(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected bool
+	}{
+		{"3", true},
+		{"-1.5", true},
+		{"1.53425E+04", true},
+		{"NaN", false},
+		{"nan", false},
+		{"Inf", false},
+		{"+Inf", false},
+		{"-Inf", false},
+		{"", false},
+		{"90d", false},
+	} {
+		if got := isFinite(tc.value); got != tc.expected {
+			t.Errorf("isFinite(%q) = %v, expected %v", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestWriteXLSXNonFiniteValues(t *testing.T) {
+	columns, err := parseColumns([]string{"name", "number_of_time_series", "number_of_ingested_samples_per_seconds"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []*PromSummary{
+		{Name: "p1", NumOfTimeSeries: "3", NumOfIngestedSamplesPerSec: "NaN"},
+		{Name: "p2", NumOfTimeSeries: "+Inf", NumOfIngestedSamplesPerSec: "1.5E+01"},
+	}
+	var b bytes.Buffer
+	if err := writeXLSX(&b, columns, results); err != nil {
+		t.Fatal(err)
+	}
+	sheet := readXLSXPart(t, b.Bytes(), "xl/worksheets/sheet1.xml")
+
+	for _, expected := range []string{
+		`<c r="B2" s="0"><v>3</v></c>`,
+		`<c r="C2" s="0" t="inlineStr"><is><t>NaN</t></is></c>`,
+		`<c r="B3" s="0" t="inlineStr"><is><t>+Inf</t></is></c>`,
+		`<c r="C3" s="0"><v>1.5E+01</v></c>`,
+	} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("expected %s in the sheet:\n%s", expected, sheet)
+		}
+	}
+	if strings.Contains(sheet, "<v>NaN") || strings.Contains(sheet, "<v>+Inf") {
+		t.Errorf("unexpected non-finite number in the sheet:\n%s", sheet)
+	}
+}
+
+func readXLSXPart(t *testing.T, content []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		part, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(part)
+	}
+	t.Fatalf("no %s part in the workbook", name)
+	return ""
+}